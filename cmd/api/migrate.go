@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/config"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+var errPostgresOnly = errors.New("This command requires STORAGE=postgres")
+
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Storage != "postgres" {
+		return errPostgresOnly
+	}
+
+	if len(cfg.DatabaseShardURLs) > 0 {
+		_, dbs, err := openShards(cfg)
+		if err != nil {
+			return err
+		}
+		defer closeAll(dbs)
+
+		err = migrateShards(cfg, dbs)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Database migrated")
+		return nil
+	}
+
+	_, db, err := openPostgres(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = applyMigrations(db.Pool())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Database migrated")
+	return nil
+}
+
+func seed(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	flags.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Storage != "postgres" {
+		return errPostgresOnly
+	}
+
+	if len(cfg.DatabaseShardURLs) > 0 {
+		repo, dbs, err := openShards(cfg)
+		if err != nil {
+			return err
+		}
+		defer closeAll(dbs)
+
+		return seedDatabase(cfg, repo)
+	}
+
+	repo, db, err := openPostgres(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return seedDatabase(cfg, repo)
+}
+
+// seedClients returns the clients listed in SEED_FILE, or the default rinha
+// clients when it isn't set.
+func seedClients(cfg config.Config) ([]repository.Client, error) {
+	if cfg.SeedFile == "" {
+		return repository.DefaultClients, nil
+	}
+
+	return repository.ReadClients(cfg.SeedFile)
+}
+
+// seeder is a storage that can insert clients up front.
+type seeder interface {
+	Seed(ctx context.Context, clients []repository.Client) error
+}
+
+func seedDatabase(cfg config.Config, repo seeder) error {
+	clients, err := seedClients(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = repo.Seed(context.Background(), clients)
+	if err != nil {
+		return fmt.Errorf("Unable to seed clients: %w", err)
+	}
+
+	fmt.Printf("Seeded %d clients\n", len(clients))
+	return nil
+}
@@ -0,0 +1,838 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/buildinfo"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/cache"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/certs"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/config"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/deadline"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/encoding"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/events"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/handler"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/health"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/httpcache"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/leader"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/logging"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/metrics"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/migrations"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/peer"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/pubsub"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/ratelimit"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/recovery"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/requestid"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/rpc"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/shed"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/tracing"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/webhook"
+	"github.com/bytedance/sonic"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// compressionLevels maps COMPRESSION to the compress middleware levels. The
+// middleware negotiates gzip, deflate or brotli from Accept-Encoding.
+var compressionLevels = map[string]compress.Level{
+	"default":          compress.LevelDefault,
+	"best-speed":       compress.LevelBestSpeed,
+	"best-compression": compress.LevelBestCompression,
+}
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	err = logging.Setup(cfg.LogLevel, cfg.LogFormat, cfg.LogFile, cfg.LogRedact)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Effective configuration:\n%s\n", cfg)
+
+	// ctx is canceled on SIGTERM or SIGINT, which starts the shutdown.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if cfg.Tracing {
+		shutdown, err := tracing.Setup(context.Background())
+		if err != nil {
+			return fmt.Errorf("Unable to set up tracing: %w", err)
+		}
+		defer shutdown(context.Background())
+	}
+
+	if cfg.MetricsOTLP {
+		shutdown, err := metrics.PushOTLP(context.Background())
+		if err != nil {
+			return fmt.Errorf("Unable to set up metrics export: %w", err)
+		}
+		defer shutdown(context.Background())
+	}
+
+	app := fiber.New(fiber.Config{
+		JSONEncoder:  sonic.Marshal,
+		JSONDecoder:  sonic.Unmarshal,
+		ErrorHandler: handler.ErrorHandler,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+		Concurrency:  cfg.HTTP.Concurrency,
+		BodyLimit:    cfg.HTTP.BodyLimit,
+		// Only the listed proxies' X-Forwarded-For is believed.
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.HTTP.TrustedProxies,
+	})
+
+	var svc *service.Service
+	var clients repository.ClientRepository
+	// webhooks is set when the /webhooks routes are served.
+	var webhooks *repository.Postgres
+	shedder := shed.New(cfg.Shed.MaxInFlight)
+
+	store, err := openCache(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if r, ok := store.(*cache.Redis); ok {
+		defer r.Close()
+	}
+
+	publisher, err := openPublisher(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if publisher != nil {
+		defer publisher.Close()
+	}
+
+	// checks back /healthz; readiness adds the ones that only matter
+	// before taking traffic.
+	var checks, readiness []health.Check
+	var gate health.Gate
+	// listening waits for the cache listeners to start.
+	var listening sync.WaitGroup
+
+	switch cfg.Storage {
+	case "memory":
+		repo, err := openMemory(cfg)
+		if err != nil {
+			return err
+		}
+
+		if cfg.SnapshotFile != "" {
+			go repo.SnapshotEvery(ctx, cfg.SnapshotFile, cfg.SnapshotInterval)
+			// Deferred calls run once in-flight requests have drained.
+			defer func() {
+				err := repo.WriteSnapshot(cfg.SnapshotFile)
+				if err != nil {
+					slog.Error("Unable to write memory snapshot", "path", cfg.SnapshotFile, "error", err)
+				}
+			}()
+		}
+
+		clients = repo
+		svc = service.New(clientRepository(cfg, repo, store), transactionRepository(repo, store))
+	case "postgres":
+		if len(cfg.DatabaseShardURLs) > 0 {
+			repo, dbs, err := openSharded(cfg)
+			if err != nil {
+				return err
+			}
+
+			clients = repo
+			svc = service.New(clientRepository(cfg, repo, store), transactionRepository(repo, store))
+			// Pool gauges and the shedder follow a single pool, so they
+			// are left out.
+			for _, db := range dbs {
+				defer db.Close()
+				checks = append(checks, db.Ping)
+				readiness = append(readiness, migrated(db))
+				if m, ok := store.(*cache.Memory); ok {
+					listening.Add(1)
+					go cache.Listen(ctx, db, m, listening.Done)
+				}
+			}
+			// Any shard can hold the lock; the leader purges them all.
+			go leadJobs(ctx, cfg, dbs[0], repo, publisher, nil)
+			break
+		}
+
+		repo, db, err := openPostgres(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		defer repo.Close()
+
+		err = bootstrap(cfg, repo, db.Pool())
+		if err != nil {
+			return err
+		}
+
+		clients = repo
+		svc = service.New(clientRepository(cfg, repo, store), transactionRepository(repo, store))
+		checks = append(checks, db.Ping)
+		readiness = append(readiness, migrated(db))
+
+		go db.Watch(ctx, cfg.FailoverInterval)
+		var dispatcher *webhook.Dispatcher
+		if cfg.Webhooks.Enabled {
+			webhooks = repo
+			dispatcher = webhook.NewDispatcher(repo, cfg.Webhooks.BatchSize, cfg.Webhooks.Timeout, cfg.Webhooks.MaxAttempts)
+		}
+		go leadJobs(ctx, cfg, db, repo, publisher, dispatcher)
+		if cfg.Metrics {
+			go metrics.WatchPool(ctx, db, cfg.PoolStatsInterval)
+		}
+		if m, ok := store.(*cache.Memory); ok {
+			listening.Add(1)
+			go cache.Listen(ctx, db, m, listening.Done)
+		}
+		if cfg.Shed.MaxAcquireWait > 0 {
+			go shedder.WatchPool(ctx, db, cfg.Shed.Interval, cfg.Shed.MaxAcquireWait)
+		}
+	case "mysql", "sqlite":
+		repo, err := repository.NewSQL(context.Background(), cfg.Storage, cfg.DatabaseURLs[0], cfg.Pool)
+		if err != nil {
+			return err
+		}
+		defer repo.Close()
+
+		err = bootstrapSQL(cfg, repo)
+		if err != nil {
+			return err
+		}
+
+		clients = repo
+		svc = service.New(clientRepository(cfg, repo, store), transactionRepository(repo, store))
+		checks = append(checks, repo.Ping)
+	}
+
+	err = svc.RefreshClients(context.Background())
+	if err != nil {
+		return fmt.Errorf("Unable to load clients: %w", err)
+	}
+	// Before the gate opens, so readiness waits for a warm cache. Listeners
+	// clear the cache as they start, so it is only warmed after them.
+	if store != nil {
+		listening.Wait()
+		err = cache.Warm(context.Background(), store, clients)
+		if err != nil {
+			return fmt.Errorf("Unable to warm the cache: %w", err)
+		}
+	}
+	go svc.WatchClients(ctx, cfg.ClientRefreshInterval)
+
+	// net/http, behind h2c, buffers whole responses, so streams and exports
+	// are only served by fasthttp.
+	var hub *pubsub.Hub[repository.Client]
+	if cfg.ServerMode == "fasthttp" {
+		hub = pubsub.New[repository.Client](cfg.StreamBuffer)
+		svc.UseHub(hub)
+	}
+	gate.SetReady(true)
+	go reloadLogLevel()
+
+	if cfg.PprofAddr != "" {
+		go servePprof(cfg.PprofAddr)
+	}
+
+	app.Use(requestid.New(), logging.Middleware())
+	if cfg.Tracing {
+		app.Use(tracing.Middleware())
+	}
+	if cfg.AccessLog {
+		app.Use(logging.AccessLog())
+	}
+	if cfg.Metrics {
+		app.Use(metrics.Middleware())
+		app.Get("/metrics", metrics.Handler())
+	}
+	app.Use(recovery.New(), deadline.New(cfg.HTTP.RequestTimeout))
+	ipLimit := ratelimit.New(cfg.RateLimit.IPRate, cfg.RateLimit.IPBurst, ratelimit.ByIP)
+	if len(cfg.Peers.URLs) > 0 {
+		// The replica a request first reaches has already counted it.
+		ipLimit = peer.Exempt(cfg.Peers.Secret, ipLimit)
+	}
+	app.Use(ipLimit)
+
+	if level, ok := compressionLevels[cfg.Compression]; ok {
+		app.Use(compress.New(compress.Config{Level: level}))
+	}
+
+	app.Get("/healthz", health.Handler(time.Second, checks...))
+	app.Get("/livez", health.Handler(time.Second))
+	app.Get("/version", buildinfo.Handler())
+	readiness = append(append([]health.Check{gate.Check}, checks...), readiness...)
+	app.Get("/readyz", health.Handler(time.Second, readiness...))
+
+	// Registered after the probes so they are never shed.
+	app.Use(shedder.Middleware())
+	if len(cfg.Peers.URLs) > 0 {
+		// Before the client limiter, so only the owner counts the request.
+		app.Use("/clientes/:id", peer.New(cfg.Peers.Self, cfg.Peers.URLs, cfg.Peers.Secret))
+	}
+	app.Use("/clientes/:id", ratelimit.New(cfg.RateLimit.ClientRate, cfg.RateLimit.ClientBurst, ratelimit.ByClient))
+
+	app.Get("/clientes/:id/extrato", httpcache.New(cfg.Cache.StatementMaxAge))
+	app.Get("/clientes/:id/saldo", httpcache.New(cfg.Cache.BalanceMaxAge))
+
+	handler.New(svc, encoding.JSON(sonic.Marshal), encoding.Protobuf(), encoding.MessagePack()).Register(app)
+	if webhooks != nil {
+		handler.NewWebhooks(webhooks, cfg.Webhooks.AdminToken).Register(app)
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.TLS.CertFile != "" {
+		reloader, err := certs.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return fmt.Errorf("Unable to load TLS certificate: %w", err)
+		}
+
+		if cfg.TLS.ReloadInterval > 0 {
+			go reloader.Watch(ctx, cfg.TLS.ReloadInterval)
+		}
+
+		tlsConfig = &tls.Config{GetCertificate: reloader.GetCertificate}
+	}
+
+	srv, err := newServer(cfg.ServerMode, app, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != 0 {
+		var opts []grpc.ServerOption
+		if tlsConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		grpcServer = rpc.NewServer(svc, cfg.HTTP.RequestTimeout, opts...)
+	}
+
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		gate.SetReady(false)
+		slog.Info("Shutting down", "timeout", cfg.ShutdownTimeout)
+		// Streams never finish on their own.
+		if hub != nil {
+			hub.Close()
+		}
+
+		stopped := make(chan struct{})
+		go func() {
+			if grpcServer != nil {
+				stopGRPC(grpcServer, cfg.ShutdownTimeout)
+			}
+			close(stopped)
+		}()
+
+		err := srv.Shutdown(cfg.ShutdownTimeout)
+		<-stopped
+		shutdown <- err
+	}()
+
+	lns, err := listen(cfg, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	served := make(chan error, len(lns)+1)
+	for _, ln := range lns {
+		go func(ln net.Listener) {
+			served <- srv.Serve(ln)
+		}(ln)
+	}
+
+	servers := len(lns)
+	if grpcServer != nil {
+		ln, err := net.Listen("tcp", ":"+strconv.Itoa(cfg.GRPCPort))
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Serving gRPC", "port", cfg.GRPCPort)
+		go func() {
+			served <- grpcServer.Serve(ln)
+		}()
+		servers++
+	}
+
+	for i := 0; i < servers; i++ {
+		err = <-served
+		if err != nil {
+			return err
+		}
+	}
+
+	// Listeners return as soon as they close; wait for in-flight requests
+	// to drain before the deferred pool.Close runs.
+	return <-shutdown
+}
+
+// leaderRepository is a repository with jobs to run on the leader.
+type leaderRepository interface {
+	repository.Purger
+	events.Outbox
+}
+
+// leadJobs runs the periodic jobs that only one replica should, while this
+// one is the leader elected through pool, until ctx is done. publisher and
+// dispatcher are nil when events and webhooks are disabled.
+func leadJobs(ctx context.Context, cfg config.Config, pool leader.Pool, repo leaderRepository, publisher events.Publisher, dispatcher *webhook.Dispatcher) {
+	var jobs []func(ctx context.Context)
+	if cfg.IdempotencyKeyTTL > 0 {
+		jobs = append(jobs, func(ctx context.Context) {
+			repository.PurgeEvery(ctx, repo, cfg.IdempotencyKeyTTL, cfg.RetentionInterval)
+		})
+	}
+	if publisher != nil {
+		jobs = append(jobs, func(ctx context.Context) {
+			events.Relay(ctx, repo, publisher, cfg.Events.BatchSize, cfg.Events.PollInterval)
+		})
+	}
+	if dispatcher != nil {
+		jobs = append(jobs, func(ctx context.Context) {
+			dispatcher.Run(ctx, cfg.Webhooks.PollInterval)
+		})
+	}
+
+	if len(jobs) == 0 {
+		return
+	}
+
+	leader.Run(ctx, pool, cfg.LeaderInterval, func(ctx context.Context) {
+		var wg sync.WaitGroup
+		for _, job := range jobs {
+			wg.Add(1)
+			go func(job func(ctx context.Context)) {
+				defer wg.Done()
+				job(ctx)
+			}(job)
+		}
+		wg.Wait()
+	})
+}
+
+// listen opens the TCP PORT and the unix socket at SOCKET_PATH, whichever
+// are enabled. They all serve the same app. tlsConfig, when not nil, is
+// applied to TCP only.
+func listen(cfg config.Config, tlsConfig *tls.Config) ([]net.Listener, error) {
+	var lns []net.Listener
+
+	if cfg.Port != 0 {
+		ln, err := net.Listen("tcp", ":"+strconv.Itoa(cfg.Port))
+		if err != nil {
+			return nil, err
+		}
+
+		if tlsConfig != nil {
+			ln = tls.NewListener(ln, tlsConfig)
+		}
+		lns = append(lns, ln)
+	}
+
+	if cfg.SocketPath != "" {
+		ln, err := listenUnix(cfg.SocketPath)
+		if err != nil {
+			for _, l := range lns {
+				l.Close()
+			}
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+
+	return lns, nil
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	// A socket left behind by a previous run would make Listen fail.
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The proxy usually runs as another user.
+	err = os.Chmod(path, 0o666)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// stopGRPC lets in-flight calls finish for up to timeout, then cuts them
+// off.
+func stopGRPC(srv *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		srv.Stop()
+	}
+}
+
+// servePprof serves the profiling endpoints on their own listener so they
+// are never reachable through the public port.
+func servePprof(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	slog.Info("Serving pprof", "addr", addr)
+
+	err := http.ListenAndServe(addr, mux)
+	if err != nil {
+		slog.Error("Unable to serve pprof", "error", err)
+	}
+}
+
+// reloadLogLevel applies LOG_LEVEL again on every SIGHUP. Other settings
+// still need a restart.
+func reloadLogLevel() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		cfg, err := config.Reload()
+		if err != nil {
+			slog.Error("Unable to reload configuration", "error", err)
+			continue
+		}
+
+		err = logging.Level.UnmarshalText([]byte(cfg.LogLevel))
+		if err != nil {
+			slog.Error("Unable to reload log level", "error", err)
+			continue
+		}
+
+		slog.Info("Reloaded log level", "level", logging.Level.Level())
+	}
+}
+
+func loadConfig() (config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return config.Config{}, fmt.Errorf("Invalid configuration:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+// openCache returns the CACHE_STORE, or nil when caching is disabled.
+func openCache(ctx context.Context, cfg config.Config) (cache.Store, error) {
+	switch cfg.CacheStore {
+	case "memory":
+		return cache.NewMemory(), nil
+	case "redis":
+		return cache.NewRedis(ctx, cfg.RedisURL, cfg.CacheTTL)
+	}
+
+	return nil, nil
+}
+
+// openPublisher connects to the EVENTS_BROKER, returning nil when events
+// are disabled.
+func openPublisher(ctx context.Context, cfg config.Config) (events.Publisher, error) {
+	if !cfg.Events.Enabled {
+		return nil, nil
+	}
+
+	switch cfg.Events.Broker {
+	case "redis":
+		return events.NewRedis(ctx, cfg.RedisURL, cfg.Events.Topic)
+	case "kafka":
+		return events.NewKafka(cfg.Events.KafkaBrokers, cfg.Events.Topic), nil
+	case "nats":
+		return events.NewNATS(cfg.Events.NATSURL, cfg.Events.Topic)
+	}
+
+	return nil, nil
+}
+
+// clientRepository puts the optional layers in front of repo: the cache,
+// then the per-client write queues, so the cache's limit check sees the
+// client's writes in order.
+func clientRepository(cfg config.Config, repo repository.ClientRepository, store cache.Store) repository.ClientRepository {
+	if store != nil {
+		repo = cache.NewRepository(repo, store)
+	}
+
+	if cfg.WriteShards > 0 {
+		repo = repository.NewSerialized(repo, cfg.WriteShards)
+	}
+
+	return repo
+}
+
+func transactionRepository(repo repository.TransactionRepository, store cache.Store) repository.TransactionRepository {
+	if store == nil {
+		return repo
+	}
+
+	return cache.NewTransactions(repo, store)
+}
+
+// openMemory restores the memory storage from MEMORY_SNAPSHOT_FILE, or
+// seeds it when there is no snapshot yet.
+func openMemory(cfg config.Config) (*repository.Memory, error) {
+	if cfg.SnapshotFile != "" {
+		repo, err := repository.LoadMemory(cfg.SnapshotFile)
+		if err == nil {
+			slog.Info("Restored memory snapshot", "path", cfg.SnapshotFile)
+			return repo, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	seeded, err := seedClients(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.NewMemory(seeded), nil
+}
+
+// openPostgres connects to the database and returns the repository along
+// with the failover manager holding its primary pool.
+func openPostgres(cfg config.Config) (*repository.Postgres, *repository.Failover, error) {
+	var tracers []pgx.QueryTracer
+	if cfg.Tracing {
+		tracers = append(tracers, tracing.QueryTracer())
+	}
+	if cfg.SlowQueryThreshold > 0 {
+		tracers = append(tracers, repository.SlowQueryTracer(cfg.SlowQueryThreshold))
+	}
+
+	db, err := repository.NewFailover(context.Background(), cfg.DatabaseURLs, cfg.Pool, tracers...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo, err := repository.NewPostgres(db, cfg.TransactionIsolation, cfg.AdvisoryLocks)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	if cfg.DatabaseReadURL != "" {
+		replica, err := repository.NewReplicaPool(context.Background(), cfg.DatabaseReadURL, cfg.Pool, tracers...)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		repo.UseReplica(replica)
+	}
+
+	if cfg.Events.Enabled && cfg.Events.Broker != "" {
+		repo.UseOutbox()
+	}
+	if cfg.Webhooks.Enabled {
+		repo.UseWebhooks()
+	}
+
+	return repo, db, nil
+}
+
+// openSharded opens and migrates every DATABASE_SHARD_URLS database, then
+// seeds the clients over them when any was fresh.
+func openSharded(cfg config.Config) (*repository.Sharded, []*repository.Failover, error) {
+	repo, dbs, err := openShards(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fail := func(err error) (*repository.Sharded, []*repository.Failover, error) {
+		closeAll(dbs)
+		return nil, nil, err
+	}
+
+	exists, err := repo.SchemaExists(context.Background())
+	if err != nil {
+		return fail(fmt.Errorf("Unable to inspect shards: %w", err))
+	}
+
+	err = migrateShards(cfg, dbs)
+	if err != nil {
+		return fail(err)
+	}
+
+	if !exists {
+		err := seedDatabase(cfg, repo)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	return repo, dbs, nil
+}
+
+// openShards connects to every DATABASE_SHARD_URLS database, returning
+// their pools in the same order.
+func openShards(cfg config.Config) (*repository.Sharded, []*repository.Failover, error) {
+	var shards []*repository.Postgres
+	var dbs []*repository.Failover
+	var names []string
+
+	fail := func(err error) (*repository.Sharded, []*repository.Failover, error) {
+		closeAll(dbs)
+		return nil, nil, err
+	}
+
+	for _, databaseURL := range cfg.DatabaseShardURLs {
+		name, err := shardName(databaseURL)
+		if err != nil {
+			return fail(err)
+		}
+
+		shardCfg := cfg
+		shardCfg.DatabaseURLs = []string{databaseURL}
+		repo, db, err := openPostgres(shardCfg)
+		if err != nil {
+			return fail(fmt.Errorf("Shard %s: %w", name, err))
+		}
+
+		dbs = append(dbs, db)
+		shards = append(shards, repo)
+		names = append(names, name)
+	}
+
+	return repository.NewSharded(shards, names), dbs, nil
+}
+
+func closeAll(dbs []*repository.Failover) {
+	for _, db := range dbs {
+		db.Close()
+	}
+}
+
+// migrateShards applies the pending migrations to each of the dbs
+// openShards returned.
+func migrateShards(cfg config.Config, dbs []*repository.Failover) error {
+	for i, db := range dbs {
+		err := applyMigrations(db.Pool())
+		if err != nil {
+			name, _ := shardName(cfg.DatabaseShardURLs[i])
+			return fmt.Errorf("Shard %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// shardName identifies a shard by host, port and database, leaving out
+// credentials and options that may change without moving any data.
+func shardName(databaseURL string) (string, error) {
+	connConfig, err := pgconn.ParseConfig(databaseURL)
+	if err != nil {
+		return "", fmt.Errorf("Invalid shard URL: %w", err)
+	}
+
+	return fmt.Sprintf("%s:%d/%s", connConfig.Host, connConfig.Port, connConfig.Database), nil
+}
+
+// migrated is a readiness check failing while db has pending migrations.
+func migrated(db *repository.Failover) health.Check {
+	return func(ctx context.Context) error {
+		pending, err := migrations.Pending(ctx, db.Pool())
+		if err == nil && len(pending) > 0 {
+			err = fmt.Errorf("pending migrations %v", pending)
+		}
+		return err
+	}
+}
+
+// bootstrap migrates the database and, when the bank schema didn't exist
+// before, seeds it so a fresh Postgres is usable right away.
+func bootstrap(cfg config.Config, repo *repository.Postgres, pool *pgxpool.Pool) error {
+	exists, err := repo.SchemaExists(context.Background())
+	if err != nil {
+		return fmt.Errorf("Unable to inspect database: %w", err)
+	}
+
+	err = applyMigrations(pool)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	return seedDatabase(cfg, repo)
+}
+
+// bootstrapSQL creates the missing tables and, when the database was empty,
+// seeds it.
+func bootstrapSQL(cfg config.Config, repo *repository.SQL) error {
+	empty, err := repo.Migrate(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if !empty {
+		return nil
+	}
+
+	return seedDatabase(cfg, repo)
+}
+
+func applyMigrations(pool *pgxpool.Pool) error {
+	applied, err := migrations.Apply(context.Background(), pool)
+	if err != nil {
+		return fmt.Errorf("Unable to migrate database: %w", err)
+	}
+
+	for _, version := range applied {
+		slog.Info("Applied migration", "version", version)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// server runs the app on listeners. Serve returns nil once Shutdown closed
+// the listener.
+type server interface {
+	Serve(ln net.Listener) error
+	Shutdown(timeout time.Duration) error
+}
+
+// newServer returns the server for SERVER_MODE. fasthttp is the fastest but
+// only speaks HTTP/1.1; h2c serves the same app through net/http, adding
+// HTTP/2, in cleartext or over TLS, at the cost of converting each request.
+func newServer(mode string, app *fiber.App, tlsConfig *tls.Config) (server, error) {
+	switch mode {
+	case "fasthttp":
+		return fasthttpServer{app: app}, nil
+	case "h2c":
+		h2s := &http2.Server{}
+		srv := &http.Server{
+			Handler:      h2c.NewHandler(http.MaxBytesHandler(adaptor.FiberApp(app), int64(app.Config().BodyLimit)), h2s),
+			ReadTimeout:  app.Config().ReadTimeout,
+			WriteTimeout: app.Config().WriteTimeout,
+			IdleTimeout:  app.Config().IdleTimeout,
+		}
+
+		if tlsConfig != nil {
+			tlsConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+			err := http2.ConfigureServer(srv, h2s)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return stdServer{srv: srv}, nil
+	default:
+		return nil, errors.New("unknown server mode " + mode)
+	}
+}
+
+type fasthttpServer struct {
+	app *fiber.App
+}
+
+func (s fasthttpServer) Serve(ln net.Listener) error {
+	return s.app.Listener(ln)
+}
+
+func (s fasthttpServer) Shutdown(timeout time.Duration) error {
+	return s.app.ShutdownWithTimeout(timeout)
+}
+
+type stdServer struct {
+	srv *http.Server
+}
+
+func (s stdServer) Serve(ln net.Listener) error {
+	err := s.srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+func (s stdServer) Shutdown(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return s.srv.Shutdown(ctx)
+}
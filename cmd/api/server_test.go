@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/encoding"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/handler"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/pubsub"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// startServer serves the handlers over a Memory repository holding client
+// 1 on a local port in mode, streams given a hub only under fasthttp, as
+// serve does. It returns the server's URL.
+func startServer(t *testing.T, mode string) string {
+	t.Helper()
+
+	repo := repository.NewMemory([]repository.Client{{ID: 1, Limit: 1000}})
+	svc := service.New(repo, repo)
+	if mode == "fasthttp" {
+		svc.UseHub(pubsub.New[repository.Client](8))
+	}
+	err := svc.RefreshClients(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = svc.CreateTransaction(context.Background(), 1, repository.Transaction{Amount: 10, Type: "c", Description: "pix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: handler.ErrorHandler,
+		WriteTimeout: time.Second,
+		BodyLimit:    4096,
+	})
+	handler.New(svc, encoding.JSON(json.Marshal)).Register(app)
+
+	srv, err := newServer(mode, app, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Shutdown(time.Second) })
+
+	return "http://" + ln.Addr().String()
+}
+
+func TestServerExport(t *testing.T) {
+	for mode, want := range map[string]int{"fasthttp": 200, "h2c": 501} {
+		url := startServer(t, mode)
+
+		res, err := http.Get(url + "/clientes/1/transacoes/export")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != want {
+			t.Fatalf("%s: got %d %s, want %d", mode, res.StatusCode, body, want)
+		}
+	}
+}
@@ -1,5 +0,0 @@
-INSERT INTO bank.clients (id, "limit", balance) VALUES(1, 100000, 0);
-INSERT INTO bank.clients (id, "limit", balance) VALUES(2, 80000, 0);
-INSERT INTO bank.clients (id, "limit", balance) VALUES(3, 1000000, 0);
-INSERT INTO bank.clients (id, "limit", balance) VALUES(4, 10000000, 0);
-INSERT INTO bank.clients (id, "limit", balance) VALUES(5, 500000, 0);
@@ -0,0 +1,47 @@
+// Package apierror renders failed requests as a JSON envelope whose erro
+// code clients can match on, with a human readable detalhe.
+package apierror
+
+import (
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/requestid"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Codes sent in the erro field.
+const (
+	InvalidID            = "id_invalido"
+	InvalidPayload       = "payload_invalido"
+	InvalidQuery         = "consulta_invalida"
+	InvalidTransaction   = "transacao_invalida"
+	InvalidClient        = "cliente_invalido"
+	ClientNotFound       = "cliente_nao_encontrado"
+	ClientExists         = "cliente_ja_existe"
+	TransactionNotFound  = "transacao_nao_encontrada"
+	NotReversible        = "transacao_nao_estornavel"
+	AlreadyReversed      = "transacao_ja_estornada"
+	LimitExceeded        = "limite_insuficiente"
+	CrossShard           = "transferencia_entre_shards"
+	WebhookNotFound      = "webhook_nao_encontrado"
+	StreamsUnavailable   = "streams_indisponiveis"
+	UnsupportedMediaType = "content_type_nao_suportado"
+	PayloadTooLarge      = "payload_muito_grande"
+	NotAcceptable        = "formato_nao_aceito"
+	Unauthorized         = "nao_autorizado"
+	RequestFailed        = "requisicao_falhou"
+	Timeout              = "tempo_esgotado"
+	Busy                 = "servico_ocupado"
+	RateLimited          = "muitas_requisicoes"
+	Internal             = "erro_interno"
+)
+
+// Error is the body of every error response.
+type Error struct {
+	Code      string `json:"erro"`
+	Detail    string `json:"detalhe,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Send writes the envelope with the given status.
+func Send(c *fiber.Ctx, status int, code, detail string) error {
+	return c.Status(status).JSON(Error{Code: code, Detail: detail, RequestID: requestid.Get(c)})
+}
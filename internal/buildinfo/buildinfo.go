@@ -0,0 +1,49 @@
+// Package buildinfo reports which build of the API is running.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Commit and BuildTime are set with -ldflags "-X". When empty, the VCS
+// stamp Go embeds in the binary is used instead.
+var (
+	Commit    string
+	BuildTime string
+)
+
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the info of the running binary.
+func Get() Info {
+	info := Info{Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// Handler serves Get as JSON.
+func Handler() fiber.Handler {
+	info := Get()
+
+	return func(c *fiber.Ctx) error {
+		return c.Status(200).JSON(info)
+	}
+}
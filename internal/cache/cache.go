@@ -0,0 +1,38 @@
+// Package cache keeps client balances and their latest transactions in
+// front of storage, so the hot paths can skip a round trip.
+package cache
+
+import (
+	"context"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+// Statement is a cached statement page: the client and its transactions,
+// newest first.
+type Statement struct {
+	Client       repository.Client
+	Transactions []repository.Transaction
+}
+
+// Store holds the cached entries. Failures are misses: a store that cannot
+// be reached just sends every request to storage.
+//
+// Every write bumps the client's version. Reads take the version before
+// going to storage and hand it back to the Add methods, which drop results
+// a write has outdated in the meantime.
+type Store interface {
+	Version(ctx context.Context, id int) uint64
+
+	Client(ctx context.Context, id int) (repository.Client, bool)
+	AddClient(ctx context.Context, version uint64, c repository.Client)
+
+	Statement(ctx context.Context, id int) (Statement, bool)
+	AddStatement(ctx context.Context, id int, version uint64, s Statement)
+
+	// Written records c as the client's state after a write, which also
+	// outdates its statement.
+	Written(ctx context.Context, c repository.Client)
+	// Invalidate drops everything cached about the client.
+	Invalidate(ctx context.Context, id int)
+}
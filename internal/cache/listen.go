@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// channel is where the clients table trigger announces changes.
+const channel = "bank_clients"
+
+// Pool is a *pgxpool.Pool, or anything standing in front of one.
+type Pool interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// Listen applies the changes other replicas make to clients to store, until
+// ctx is done. Notifications missed while reconnecting cannot be replayed,
+// so store is cleared every time the listener (re)starts. listening is
+// called once the first start cleared store, or when Listen returns before
+// that; warming store before then would be wasted.
+func Listen(ctx context.Context, pool Pool, store *Memory, listening func()) {
+	var once sync.Once
+	started := func() { once.Do(listening) }
+	defer started()
+
+	backoff := 100 * time.Millisecond
+
+	for {
+		err := listen(ctx, pool, store, started)
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.Warn("Client change listener stopped, restarting", "in", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
+
+func listen(ctx context.Context, pool Pool, store *Memory, started func()) error {
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The session keeps listening, so it must not go back to the pool.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+channel)
+	if err != nil {
+		return err
+	}
+
+	store.Clear()
+	started()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = apply(store, n.Payload)
+		if err != nil {
+			slog.Error("Invalid client change notification", "payload", n.Payload, "error", err)
+		}
+	}
+}
+
+// apply handles an "id" or "id,balance,limit" payload.
+func apply(store *Memory, payload string) error {
+	fields := strings.Split(payload, ",")
+
+	values := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+
+	switch len(values) {
+	case 1:
+		store.Invalidate(context.Background(), values[0])
+	case 3:
+		store.Written(context.Background(), repository.Client{ID: values[0], Balance: values[1], Limit: values[2]})
+	default:
+		return fmt.Errorf("expected 1 or 3 fields, got %d", len(values))
+	}
+
+	return nil
+}
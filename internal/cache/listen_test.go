@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type unreachable struct{}
+
+func (unreachable) Acquire(context.Context) (*pgxpool.Conn, error) {
+	return nil, errors.New("connection refused")
+}
+
+// Startup waits on listening to warm the cache, so it must be called even
+// when Listen never got to listen.
+func TestListenCallsListeningOnReturn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	Listen(ctx, unreachable{}, NewMemory(), func() { calls++ })
+	if calls != 1 {
+		t.Fatalf("listening called %d times, want 1", calls)
+	}
+}
+
+func TestApply(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+
+	err := apply(store, "1,-50,100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := store.Client(ctx, 1)
+	if !ok || c != (repository.Client{ID: 1, Balance: -50, Limit: 100}) {
+		t.Fatalf("got %+v, %v", c, ok)
+	}
+
+	err = apply(store, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Client(ctx, 1); ok {
+		t.Fatal("deleted client still cached")
+	}
+
+	for _, payload := range []string{"", "1,2", "x,1,2"} {
+		if apply(store, payload) == nil {
+			t.Fatalf("%q applied", payload)
+		}
+	}
+}
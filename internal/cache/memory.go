@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+const shardCount = 16
+
+type shard struct {
+	mu sync.RWMutex
+	// epoch is bumped by Clear, so versions taken before it never match
+	// again, even for clients that were never written.
+	epoch      uint64
+	versions   map[int]uint64
+	clients    map[int]repository.Client
+	statements map[int]Statement
+}
+
+// Memory is an in-process Store, sharded by client id to keep lock
+// contention down. Replicas keep theirs current through Listen.
+type Memory struct {
+	shards [shardCount]shard
+}
+
+func NewMemory() *Memory {
+	m := &Memory{}
+	for i := range m.shards {
+		m.shards[i].versions = make(map[int]uint64)
+		m.shards[i].clients = make(map[int]repository.Client)
+		m.shards[i].statements = make(map[int]Statement)
+	}
+
+	return m
+}
+
+func (m *Memory) shard(id int) *shard {
+	return &m.shards[uint(id)%shardCount]
+}
+
+func (s *shard) version(id int) uint64 {
+	return s.epoch<<32 | s.versions[id]
+}
+
+func (m *Memory) Version(_ context.Context, id int) uint64 {
+	s := m.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.version(id)
+}
+
+func (m *Memory) Client(_ context.Context, id int) (repository.Client, bool) {
+	s := m.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.clients[id]
+	return c, ok
+}
+
+func (m *Memory) AddClient(_ context.Context, version uint64, c repository.Client) {
+	s := m.shard(c.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.version(c.ID) == version {
+		s.clients[c.ID] = c
+	}
+}
+
+func (m *Memory) Statement(_ context.Context, id int) (Statement, bool) {
+	s := m.shard(id)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, ok := s.statements[id]
+	return st, ok
+}
+
+func (m *Memory) AddStatement(_ context.Context, id int, version uint64, st Statement) {
+	s := m.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.version(id) == version {
+		s.statements[id] = st
+	}
+}
+
+func (m *Memory) Written(_ context.Context, c repository.Client) {
+	s := m.shard(c.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.versions[c.ID]++
+	s.clients[c.ID] = c
+	delete(s.statements, c.ID)
+}
+
+func (m *Memory) Invalidate(_ context.Context, id int) {
+	s := m.shard(id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.versions[id]++
+	delete(s.clients, id)
+	delete(s.statements, id)
+}
+
+// Clear drops every entry, along with what reads in flight would add.
+func (m *Memory) Clear() {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		s.epoch++
+		clear(s.versions)
+		clear(s.clients)
+		clear(s.statements)
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// addIfVersion sets KEYS[2] to ARGV[2] for ARGV[3] milliseconds, unless the
+// version at KEYS[1] is no longer ARGV[1].
+var addIfVersion = redis.NewScript(`
+if (redis.call('GET', KEYS[1]) or '0') == ARGV[1] then
+	redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+end
+return 0
+`)
+
+// Redis is a Store shared by every replica. Writes only invalidate: with
+// several replicas writing, only storage knows which balance is the latest,
+// so the next read reloads it.
+type Redis struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedis connects to the redis:// URL. Entries expire after ttl, which
+// bounds how long anything missed stays stale.
+func NewRedis(ctx context.Context, url string, ttl time.Duration) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Redis URL: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	err = client.Ping(ctx).Err()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("Unable to ping Redis: %w", err)
+	}
+
+	return &Redis{client: client, ttl: ttl}, nil
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// Keys share the {id} hash tag, so a client's keys live in one cluster slot
+// and the version script may touch them together.
+func versionKey(id int) string {
+	return fmt.Sprintf("rinha:{%d}:version", id)
+}
+
+func clientKey(id int) string {
+	return fmt.Sprintf("rinha:{%d}:client", id)
+}
+
+func statementKey(id int) string {
+	return fmt.Sprintf("rinha:{%d}:statement", id)
+}
+
+func (r *Redis) Version(ctx context.Context, id int) uint64 {
+	v, err := r.client.Get(ctx, versionKey(id)).Uint64()
+	if err != nil && err != redis.Nil {
+		slog.Warn("Unable to read from Redis", "error", err)
+	}
+
+	return v
+}
+
+func (r *Redis) Client(ctx context.Context, id int) (repository.Client, bool) {
+	var c repository.Client
+	return c, r.get(ctx, clientKey(id), &c)
+}
+
+func (r *Redis) AddClient(ctx context.Context, version uint64, c repository.Client) {
+	r.add(ctx, c.ID, clientKey(c.ID), version, c)
+}
+
+func (r *Redis) Statement(ctx context.Context, id int) (Statement, bool) {
+	var s Statement
+	return s, r.get(ctx, statementKey(id), &s)
+}
+
+func (r *Redis) AddStatement(ctx context.Context, id int, version uint64, s Statement) {
+	r.add(ctx, id, statementKey(id), version, s)
+}
+
+func (r *Redis) Written(ctx context.Context, c repository.Client) {
+	r.Invalidate(ctx, c.ID)
+}
+
+func (r *Redis) Invalidate(ctx context.Context, id int) {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, versionKey(id))
+		pipe.Del(ctx, clientKey(id), statementKey(id))
+		return nil
+	})
+	if err != nil {
+		slog.Warn("Unable to invalidate Redis entries", "client_id", id, "error", err)
+	}
+}
+
+func (r *Redis) get(ctx context.Context, key string, v any) bool {
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			slog.Warn("Unable to read from Redis", "error", err)
+		}
+		return false
+	}
+
+	return json.Unmarshal(data, v) == nil
+}
+
+func (r *Redis) add(ctx context.Context, id int, key string, version uint64, v any) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = addIfVersion.Run(ctx, r.client,
+			[]string{versionKey(id), key},
+			strconv.FormatUint(version, 10), data, r.ttl.Milliseconds(),
+		).Err()
+	}
+
+	if err != nil {
+		slog.Warn("Unable to write to Redis", "error", err)
+	}
+}
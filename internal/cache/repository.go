@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+// Repository serves FindClient from a Store and records every write in it.
+// Writes always go to storage, which alone decides on the limit: writes may
+// be recorded out of order, leaving an entry older than storage, which must
+// neither let a debit through nor turn one away.
+type Repository struct {
+	repository.ClientRepository
+	store Store
+}
+
+func NewRepository(repo repository.ClientRepository, store Store) *Repository {
+	return &Repository{ClientRepository: repo, store: store}
+}
+
+func (r *Repository) FindClient(ctx context.Context, id int) (repository.Client, error) {
+	if c, ok := r.store.Client(ctx, id); ok {
+		return c, nil
+	}
+
+	version := r.store.Version(ctx, id)
+
+	c, err := r.ClientRepository.FindClient(ctx, id)
+	if err == nil {
+		r.store.AddClient(ctx, version, c)
+	}
+
+	return c, err
+}
+
+func (r *Repository) CreateClient(ctx context.Context, c repository.Client) error {
+	err := r.ClientRepository.CreateClient(ctx, c)
+	if err == nil {
+		r.store.Written(ctx, c)
+	}
+
+	return err
+}
+
+func (r *Repository) UpdateLimit(ctx context.Context, id int, limit int) (repository.Client, error) {
+	c, err := r.ClientRepository.UpdateLimit(ctx, id, limit)
+	r.update(ctx, id, c, err)
+
+	return c, err
+}
+
+func (r *Repository) DeleteClient(ctx context.Context, id int) error {
+	err := r.ClientRepository.DeleteClient(ctx, id)
+	r.store.Invalidate(ctx, id)
+
+	return err
+}
+
+func (r *Repository) ApplyTransaction(ctx context.Context, clientID int, t repository.Transaction) (repository.Client, repository.Transaction, error) {
+	// A replayed idempotency key returns the client as it stood back then,
+	// whatever the balance is now, so those skip the cache both ways.
+	if t.IdempotencyKey != "" {
+		c, tr, err := r.ClientRepository.ApplyTransaction(ctx, clientID, t)
+		r.store.Invalidate(ctx, clientID)
+
+		return c, tr, err
+	}
+
+	c, tr, err := r.ClientRepository.ApplyTransaction(ctx, clientID, t)
+	r.update(ctx, clientID, c, err)
+
+	return c, tr, err
+}
+
+func (r *Repository) ApplyTransactions(ctx context.Context, clientID int, ts []repository.Transaction) ([]repository.Client, error) {
+	clients, err := r.ClientRepository.ApplyTransactions(ctx, clientID, ts)
+	if err == nil && len(clients) > 0 {
+		r.store.Written(ctx, clients[len(clients)-1])
+	} else {
+		r.store.Invalidate(ctx, clientID)
+	}
+
+	return clients, err
+}
+
+func (r *Repository) Transfer(ctx context.Context, fromID, toID int, t repository.Transaction) (repository.Client, error) {
+	c, err := r.ClientRepository.Transfer(ctx, fromID, toID, t)
+	r.update(ctx, fromID, c, err)
+	// Only the sender comes back, so the recipient is reloaded on its next
+	// read.
+	r.store.Invalidate(ctx, toID)
+
+	return c, err
+}
+
+func (r *Repository) Reverse(ctx context.Context, clientID int, transactionID int64) (repository.Client, error) {
+	c, err := r.ClientRepository.Reverse(ctx, clientID, transactionID)
+	r.update(ctx, clientID, c, err)
+
+	return c, err
+}
+
+// update records the client a write returned. A failed write may mean the
+// entry was stale, e.g. storage refusing a debit it allowed, so it is
+// dropped to be reloaded.
+func (r *Repository) update(ctx context.Context, id int, c repository.Client, err error) {
+	switch {
+	case err == nil:
+		r.store.Written(ctx, c)
+	case errors.Is(err, repository.ErrLimitExceeded), errors.Is(err, repository.ErrClientNotFound):
+		r.store.Invalidate(ctx, id)
+	}
+}
+
+// statementQuery is the only query cached: the default first page, which is
+// what the rinha load asks for.
+var statementQuery = repository.StatementQuery{Limit: 10}
+
+// Transactions serves the default statement page from a Store.
+type Transactions struct {
+	repository.TransactionRepository
+	store Store
+}
+
+func NewTransactions(repo repository.TransactionRepository, store Store) *Transactions {
+	return &Transactions{TransactionRepository: repo, store: store}
+}
+
+func (t *Transactions) Statement(ctx context.Context, clientID int, q repository.StatementQuery) (repository.Client, []repository.Transaction, error) {
+	if q != statementQuery {
+		return t.TransactionRepository.Statement(ctx, clientID, q)
+	}
+
+	if s, ok := t.store.Statement(ctx, clientID); ok {
+		return s.Client, s.Transactions, nil
+	}
+
+	version := t.store.Version(ctx, clientID)
+
+	c, ts, err := t.TransactionRepository.Statement(ctx, clientID, q)
+	if err == nil {
+		t.store.AddStatement(ctx, clientID, version, Statement{Client: c, Transactions: ts})
+	}
+
+	return c, ts, err
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+// counting counts the calls reaching storage.
+type counting struct {
+	*repository.Memory
+	finds, applies, statements int
+}
+
+func (c *counting) FindClient(ctx context.Context, id int) (repository.Client, error) {
+	c.finds++
+	return c.Memory.FindClient(ctx, id)
+}
+
+func (c *counting) ApplyTransaction(ctx context.Context, clientID int, t repository.Transaction) (repository.Client, repository.Transaction, error) {
+	c.applies++
+	return c.Memory.ApplyTransaction(ctx, clientID, t)
+}
+
+func (c *counting) Statement(ctx context.Context, clientID int, q repository.StatementQuery) (repository.Client, []repository.Transaction, error) {
+	c.statements++
+	return c.Memory.Statement(ctx, clientID, q)
+}
+
+func newCounting() *counting {
+	return &counting{Memory: repository.NewMemory([]repository.Client{{ID: 1, Limit: 100}, {ID: 2, Limit: 100}})}
+}
+
+func TestRepositoryFindClient(t *testing.T) {
+	repo := newCounting()
+	cached := NewRepository(repo, NewMemory())
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := cached.FindClient(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.finds != 1 {
+		t.Fatalf("storage was read %d times, want 1", repo.finds)
+	}
+
+	_, _, err := cached.ApplyTransaction(ctx, 1, repository.Transaction{Amount: 30, Type: "c", Description: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cached.FindClient(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Balance != 30 || repo.finds != 1 {
+		t.Fatalf("got balance %d after %d reads, want the written 30 from the cache", c.Balance, repo.finds)
+	}
+
+	_, err = cached.FindClient(ctx, 99)
+	if !errors.Is(err, repository.ErrClientNotFound) {
+		t.Fatalf("got %v, want ErrClientNotFound", err)
+	}
+}
+
+func TestRepositoryDebitAgainstOutdatedEntry(t *testing.T) {
+	repo := newCounting()
+	store := NewMemory()
+	cached := NewRepository(repo, store)
+	ctx := context.Background()
+
+	// A write recorded after a later one, leaving the entry lower than
+	// storage.
+	store.Written(ctx, repository.Client{ID: 1, Balance: -100, Limit: 100})
+
+	_, _, err := cached.ApplyTransaction(ctx, 1, repository.Transaction{Amount: 100, Type: "d", Description: "a"})
+	if err != nil || repo.applies != 1 {
+		t.Fatalf("got %v after %d writes, want the debit applied by storage", err, repo.applies)
+	}
+
+	_, _, err = cached.ApplyTransaction(ctx, 1, repository.Transaction{Amount: 1, Type: "d", Description: "a"})
+	if !errors.Is(err, repository.ErrLimitExceeded) || repo.applies != 2 {
+		t.Fatalf("got %v after %d writes, want ErrLimitExceeded from storage", err, repo.applies)
+	}
+}
+
+func TestRepositoryStaleEntry(t *testing.T) {
+	repo := newCounting()
+	cached := NewRepository(repo, NewMemory())
+	ctx := context.Background()
+
+	_, err := cached.FindClient(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Written behind the cache's back, e.g. by another replica.
+	_, _, err = repo.Memory.ApplyTransaction(ctx, 1, repository.Transaction{Amount: 100, Type: "d", Description: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = cached.ApplyTransaction(ctx, 1, repository.Transaction{Amount: 50, Type: "d", Description: "a"})
+	if !errors.Is(err, repository.ErrLimitExceeded) {
+		t.Fatalf("got %v, want ErrLimitExceeded from storage", err)
+	}
+
+	c, err := cached.FindClient(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Balance != -100 || repo.finds != 2 {
+		t.Fatalf("got balance %d after %d reads, want -100 reloaded", c.Balance, repo.finds)
+	}
+}
+
+func TestRepositoryIdempotencyKey(t *testing.T) {
+	repo := newCounting()
+	cached := NewRepository(repo, NewMemory())
+	ctx := context.Background()
+
+	tr := repository.Transaction{Amount: 10, Type: "c", Description: "a", IdempotencyKey: "k"}
+	_, first, err := cached.ApplyTransaction(ctx, 1, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = cached.ApplyTransaction(ctx, 1, repository.Transaction{Amount: 5, Type: "c", Description: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, again, err := cached.ApplyTransaction(ctx, 1, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || replayed.Balance != 10 {
+		t.Fatalf("replay got id %d, balance %d; want %d, 10", again.ID, replayed.Balance, first.ID)
+	}
+
+	// The replayed balance is history, not cached as current.
+	c, err := cached.FindClient(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Balance != 15 {
+		t.Fatalf("got balance %d, want 15", c.Balance)
+	}
+}
+
+func TestTransactionsStatement(t *testing.T) {
+	repo := newCounting()
+	store := NewMemory()
+	clients := NewRepository(repo, store)
+	transactions := NewTransactions(repo, store)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, _, err := transactions.Statement(ctx, 1, statementQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.statements != 1 {
+		t.Fatalf("storage was read %d times, want 1", repo.statements)
+	}
+
+	_, _, err := clients.ApplyTransaction(ctx, 1, repository.Transaction{Amount: 10, Type: "c", Description: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ts, err := transactions.Statement(ctx, 1, statementQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 1 || repo.statements != 2 {
+		t.Fatalf("got %d transactions after %d reads, want the write reloaded", len(ts), repo.statements)
+	}
+
+	// Other pages always go to storage.
+	_, _, err = transactions.Statement(ctx, 1, repository.StatementQuery{Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.statements != 3 {
+		t.Fatalf("storage was read %d times, want 3", repo.statements)
+	}
+}
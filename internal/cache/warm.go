@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+// Warm loads every client into store, so the first requests don't pay for
+// cold reads.
+func Warm(ctx context.Context, store Store, repo repository.ClientRepository) error {
+	clients, err := repo.Clients(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range clients {
+		store.AddClient(ctx, store.Version(ctx, c.ID), c)
+	}
+
+	return nil
+}
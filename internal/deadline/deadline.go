@@ -0,0 +1,28 @@
+// Package deadline bounds how long a request may spend in storage, so a
+// stuck query or row lock cannot hold a pool connection indefinitely.
+package deadline
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// New returns a middleware that gives the request's user context a timeout.
+// Work past it fails with context.DeadlineExceeded, which the app's
+// ErrorHandler answers with 504. A zero timeout disables it.
+func New(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if timeout <= 0 {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+
+		c.SetUserContext(ctx)
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+// Package encoding holds the wire formats responses can be negotiated into.
+package encoding
+
+import "errors"
+
+// ErrUnsupported is returned when a value can't be written in a format.
+var ErrUnsupported = errors.New("value not supported by this encoding")
+
+// Encoder serializes response DTOs in one wire format.
+type Encoder interface {
+	ContentType() string
+	Encode(v any) ([]byte, error)
+}
+
+type jsonEncoder struct {
+	marshal func(v any) ([]byte, error)
+}
+
+// JSON encodes with the given marshal function, usually the app's
+// JSONEncoder.
+func JSON(marshal func(v any) ([]byte, error)) Encoder {
+	return jsonEncoder{marshal: marshal}
+}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (e jsonEncoder) Encode(v any) ([]byte, error) {
+	return e.marshal(v)
+}
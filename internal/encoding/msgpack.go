@@ -0,0 +1,32 @@
+package encoding
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type msgpackEncoder struct{}
+
+// MessagePack encodes DTOs as msgpack maps keyed by their json tags, so the
+// field names match the JSON responses.
+func MessagePack() Encoder {
+	return msgpackEncoder{}
+}
+
+func (msgpackEncoder) ContentType() string {
+	return "application/msgpack"
+}
+
+func (msgpackEncoder) Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
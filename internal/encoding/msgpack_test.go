@@ -0,0 +1,31 @@
+package encoding
+
+import (
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMessagePack(t *testing.T) {
+	type dto struct {
+		Limit   int    `json:"limite"`
+		Balance int    `json:"saldo"`
+		Next    string `json:"proxima_pagina,omitempty"`
+	}
+
+	b, err := MessagePack().Encode(dto{Limit: 1000, Balance: -300})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]int
+	err = msgpack.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keyed like the JSON responses, omitempty included.
+	if len(got) != 2 || got["limite"] != 1000 || got["saldo"] != -300 {
+		t.Fatalf("got %v", got)
+	}
+}
@@ -0,0 +1,88 @@
+package encoding
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtoAppender is implemented by DTOs that have a message in
+// proto/rinha.proto. AppendProto appends the message's wire encoding to b.
+type ProtoAppender interface {
+	AppendProto(b []byte) []byte
+}
+
+type protobufEncoder struct{}
+
+// Protobuf encodes values implementing ProtoAppender.
+func Protobuf() Encoder {
+	return protobufEncoder{}
+}
+
+func (protobufEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (protobufEncoder) Encode(v any) ([]byte, error) {
+	m, ok := v.(ProtoAppender)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	return m.AppendProto(nil), nil
+}
+
+// The helpers below skip zero values, as proto3 does for scalar fields
+// without presence.
+
+func AppendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func AppendSint(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+
+	return AppendSintAlways(b, num, v)
+}
+
+// AppendSintAlways writes v even when zero, for optional fields.
+func AppendSintAlways(b []byte, num protowire.Number, v int64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, protowire.EncodeZigZag(v))
+}
+
+func AppendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// AppendMessage writes an embedded message, even when it's empty.
+func AppendMessage(b []byte, num protowire.Number, m ProtoAppender) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.AppendProto(nil))
+}
+
+// AppendTimestamp writes t as a google.protobuf.Timestamp.
+func AppendTimestamp(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+
+	var ts []byte
+	ts = AppendInt(ts, 1, t.Unix())
+	ts = AppendInt(ts, 2, int64(t.Nanosecond()))
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, ts)
+}
@@ -0,0 +1,72 @@
+package encoding
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type testMessage struct {
+	Limit   int
+	Balance int
+	Type    string
+	At      time.Time
+}
+
+func (m testMessage) AppendProto(b []byte) []byte {
+	b = AppendInt(b, 1, int64(m.Limit))
+	b = AppendSint(b, 2, int64(m.Balance))
+	b = AppendString(b, 3, m.Type)
+	return AppendTimestamp(b, 4, m.At)
+}
+
+func TestProtobuf(t *testing.T) {
+	at := time.Date(2024, 2, 1, 12, 30, 0, 500, time.UTC)
+
+	b, err := Protobuf().Encode(testMessage{Limit: 1000, Balance: -300, Type: "d", At: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[protowire.Number]any{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			got[num], b = v, b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			got[num], b = v, b[n:]
+		default:
+			t.Fatalf("field %d has wire type %d", num, typ)
+		}
+	}
+
+	if got[1] != uint64(1000) || protowire.DecodeZigZag(got[2].(uint64)) != -300 || string(got[3].([]byte)) != "d" {
+		t.Fatalf("got fields %v", got)
+	}
+
+	var ts timestamppb.Timestamp
+	err = proto.Unmarshal(got[4].([]byte), &ts)
+	if err != nil || !ts.AsTime().Equal(at) {
+		t.Fatalf("got timestamp %v, %v", ts.AsTime(), err)
+	}
+
+	// Zero values are left out, as proto3 does.
+	b, err = Protobuf().Encode(testMessage{})
+	if err != nil || len(b) != 0 {
+		t.Fatalf("got %x, %v", b, err)
+	}
+
+	_, err = Protobuf().Encode(struct{}{})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("got %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Package events publishes the domain events queued in the outbox to a
+// message broker.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+// Publisher delivers events to a broker. Publish returns once the broker
+// has accepted all of them, or fails if any may not have been.
+type Publisher interface {
+	Publish(ctx context.Context, events []repository.Event) error
+	Close() error
+}
+
+// Outbox is a repository queuing events with its writes.
+type Outbox interface {
+	RelayOutbox(ctx context.Context, limit int, publish func(context.Context, []repository.Event) error) (int, error)
+}
+
+// Relay publishes the events queued in outbox, batch events at a time,
+// until ctx is done. It drains the outbox, then checks it again every
+// interval, backing off while the broker is failing. Only one replica
+// should relay at a time, or events of one client could be published out
+// of order.
+func Relay(ctx context.Context, outbox Outbox, publisher Publisher, batch int, interval time.Duration) {
+	wait := interval
+
+	for {
+		for {
+			n, err := outbox.RelayOutbox(ctx, batch, publisher.Publish)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				wait = min(max(wait*2, interval), 5*time.Second)
+				slog.Error("Unable to relay events, retrying", "in", wait, "error", err)
+				break
+			}
+
+			wait = interval
+			if n < batch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
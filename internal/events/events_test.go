@@ -0,0 +1,148 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+// The broker tests need a broker they may write to: TEST_REDIS_URL,
+// TEST_KAFKA_BROKERS, comma separated, and TEST_NATS_URL, one for each
+// publisher. They are skipped when unset.
+
+// testEvents are published by every broker test.
+var testEvents = []repository.Event{
+	{ID: 1, ClientID: 1, Type: "TransactionCreated", Payload: []byte(`{"valor":10}`)},
+	{ID: 2, ClientID: 2, Type: "LimitChanged", Payload: []byte(`{"limite":500}`)},
+}
+
+// fakeOutbox queues events in memory, removing them once published.
+type fakeOutbox struct {
+	mu     sync.Mutex
+	events []repository.Event
+}
+
+func (o *fakeOutbox) RelayOutbox(ctx context.Context, limit int, publish func(context.Context, []repository.Event) error) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	batch := o.events[:min(limit, len(o.events))]
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	err := publish(ctx, batch)
+	if err != nil {
+		return 0, err
+	}
+
+	o.events = o.events[len(batch):]
+
+	return len(batch), nil
+}
+
+func (o *fakeOutbox) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.events)
+}
+
+// fakePublisher fails its first fail calls and records the events of the
+// others.
+type fakePublisher struct {
+	fail      int
+	published []repository.Event
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, events []repository.Event) error {
+	if p.fail > 0 {
+		p.fail--
+		return errors.New("broker down")
+	}
+
+	p.published = append(p.published, events...)
+	return nil
+}
+
+func (p *fakePublisher) Close() error {
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	outbox := &fakeOutbox{}
+	for i := 1; i <= 5; i++ {
+		outbox.events = append(outbox.events, repository.Event{ID: int64(i), ClientID: 1, Type: "TransactionCreated"})
+	}
+	publisher := &fakePublisher{fail: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Relay(ctx, outbox, publisher, 2, 10*time.Millisecond)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for outbox.len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if len(publisher.published) != 5 {
+		t.Fatalf("published %d events, want 5", len(publisher.published))
+	}
+	for i, e := range publisher.published {
+		if e.ID != int64(i+1) {
+			t.Fatalf("published event %d as #%d", e.ID, i+1)
+		}
+	}
+}
+
+func TestRedis(t *testing.T) {
+	url := os.Getenv("TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("TEST_REDIS_URL is not set")
+	}
+	ctx := context.Background()
+
+	stream := "test-events-" + time.Now().Format("150405.000000000")
+	publisher, err := NewRedis(ctx, url, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	defer publisher.client.Del(ctx, stream)
+
+	err = publisher.Publish(ctx, testEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := publisher.client.XRange(ctx, stream, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(testEvents) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(testEvents))
+	}
+
+	for i, e := range testEvents {
+		want := map[string]any{
+			"id":        strconv.FormatInt(e.ID, 10),
+			"type":      e.Type,
+			"client_id": strconv.Itoa(e.ClientID),
+			"payload":   string(e.Payload),
+		}
+		if !maps.Equal(entries[i].Values, want) {
+			t.Fatalf("got entry %v, want %v", entries[i].Values, want)
+		}
+	}
+}
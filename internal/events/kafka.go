@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka produces events to a Kafka topic, keyed by client id so each
+// client's events land in one partition, in order.
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+// NewKafka returns a producer for topic on brokers, given as host:port.
+// Nothing is dialed until the first Publish, so a broker that is down at
+// startup only delays events, which wait in the outbox meanwhile.
+func NewKafka(brokers []string, topic string) *Kafka {
+	return &Kafka{writer: &kafka.Writer{
+		Addr:     kafka.TCP(brokers...),
+		Topic:    topic,
+		Balancer: &kafka.Hash{},
+		// Acknowledged by every in-sync replica, or the batch is retried.
+		RequiredAcks: kafka.RequireAll,
+		// Publish already hands over whole batches, so don't wait for
+		// more.
+		BatchTimeout: time.Millisecond,
+	}}
+}
+
+// Publish produces one message per event, with the payload as the value
+// and the event id and type in headers.
+func (k *Kafka) Publish(ctx context.Context, events []repository.Event) error {
+	msgs := make([]kafka.Message, len(events))
+	for i, e := range events {
+		msgs[i] = kafka.Message{
+			Key:   []byte(strconv.Itoa(e.ClientID)),
+			Value: e.Payload,
+			Headers: []kafka.Header{
+				{Key: "id", Value: []byte(strconv.FormatInt(e.ID, 10))},
+				{Key: "type", Value: []byte(e.Type)},
+			},
+		}
+	}
+
+	return k.writer.WriteMessages(ctx, msgs...)
+}
+
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
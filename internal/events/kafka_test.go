@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKafka(t *testing.T) {
+	brokers := os.Getenv("TEST_KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("TEST_KAFKA_BROKERS is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	topic := "test-events-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	createTopic(t, strings.Split(brokers, ",")[0], topic)
+
+	publisher := NewKafka(strings.Split(brokers, ","), topic)
+	defer publisher.Close()
+
+	err := publisher.Publish(ctx, testEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: strings.Split(brokers, ","), Topic: topic})
+	defer reader.Close()
+
+	for _, e := range testEvents {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		headers := map[string]string{}
+		for _, h := range msg.Headers {
+			headers[h.Key] = string(h.Value)
+		}
+
+		if string(msg.Key) != strconv.Itoa(e.ClientID) || string(msg.Value) != string(e.Payload) ||
+			headers["id"] != strconv.FormatInt(e.ID, 10) || headers["type"] != e.Type {
+			t.Fatalf("got message %s=%s with headers %v, want event %+v", msg.Key, msg.Value, headers, e)
+		}
+	}
+}
+
+// createTopic creates a single partition topic, so its messages are read
+// back in the order produced.
+func createTopic(t *testing.T, broker, topic string) {
+	t.Helper()
+
+	conn, err := kafka.Dial("tcp", broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controllerConn, err := kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer controllerConn.Close()
+
+	err = controllerConn.CreateTopics(kafka.TopicConfig{Topic: topic, NumPartitions: 1, ReplicationFactor: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsAckTimeout bounds how long Publish waits for JetStream to
+// acknowledge a batch, since messages sent while disconnected sit in the
+// reconnect buffer until the server comes back.
+const natsAckTimeout = 5 * time.Second
+
+// NATS publishes events to JetStream, on the subject prefix followed by
+// the event type, e.g. bank.transactions.TransactionCreated. A stream
+// must capture those subjects; until one does, publishing fails and events
+// wait in the outbox.
+type NATS struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	prefix string
+}
+
+// NewNATS connects to the nats:// URL. The connection is retried in the
+// background, forever, both at startup and whenever it drops.
+func NewNATS(url string, prefix string) (*NATS, error) {
+	conn, err := nats.Connect(url,
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				slog.Warn("Disconnected from NATS", "error", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			slog.Info("Reconnected to NATS", "url", conn.ConnectedUrlRedacted())
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to NATS: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &NATS{conn: conn, js: js, prefix: prefix}, nil
+}
+
+// Publish sends every event before waiting for the acknowledgements. The
+// event id is the message id, so JetStream drops the duplicates of a batch
+// published again within its deduplication window.
+func (n *NATS) Publish(ctx context.Context, events []repository.Event) error {
+	// Fail fast rather than buffer: the events are safe in the outbox.
+	if !n.conn.IsConnected() {
+		return fmt.Errorf("NATS connection is %s", n.conn.Status())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, natsAckTimeout)
+	defer cancel()
+
+	acks := make([]jetstream.PubAckFuture, len(events))
+	for i, e := range events {
+		msg := nats.NewMsg(n.prefix + "." + e.Type)
+		msg.Data = e.Payload
+		msg.Header.Set(jetstream.MsgIDHeader, strconv.FormatInt(e.ID, 10))
+		msg.Header.Set("Client-Id", strconv.Itoa(e.ClientID))
+
+		ack, err := n.js.PublishMsgAsync(msg)
+		if err != nil {
+			return err
+		}
+		acks[i] = ack
+	}
+
+	for _, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Close flushes what is pending and closes the connection.
+func (n *NATS) Close() error {
+	if !n.conn.IsConnected() {
+		n.conn.Close()
+		return nil
+	}
+
+	return n.conn.Drain()
+}
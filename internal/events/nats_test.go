@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestNATSDisconnected(t *testing.T) {
+	// A port nothing listens on.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+
+	publisher, err := NewNATS("nats://"+ln.Addr().String(), "bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	start := time.Now()
+	err = publisher.Publish(context.Background(), testEvents)
+	if err == nil || time.Since(start) > time.Second {
+		t.Fatalf("got %v after %s, want a prompt error", err, time.Since(start))
+	}
+}
+
+func TestNATS(t *testing.T) {
+	url := os.Getenv("TEST_NATS_URL")
+	if url == "" {
+		t.Skip("TEST_NATS_URL is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	prefix := "test" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	publisher, err := NewNATS(url, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	stream, err := publisher.js.CreateStream(ctx, jetstream.StreamConfig{Name: prefix, Subjects: []string{prefix + ".>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.js.DeleteStream(context.Background(), prefix)
+
+	// Published again, as after a failed relay, the batch is deduplicated.
+	for i := 0; i < 2; i++ {
+		err = publisher.Publish(ctx, testEvents)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != uint64(len(testEvents)) {
+		t.Fatalf("got %d messages, want %d", info.State.Msgs, len(testEvents))
+	}
+
+	for i, e := range testEvents {
+		msg, err := stream.GetMsg(ctx, uint64(i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if msg.Subject != prefix+"."+e.Type || string(msg.Data) != string(e.Payload) ||
+			msg.Header.Get("Client-Id") != strconv.Itoa(e.ClientID) {
+			t.Fatalf("got %s %s with headers %v, want event %+v", msg.Subject, msg.Data, msg.Header, e)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis appends events to a Redis stream, for consumer groups to read.
+type Redis struct {
+	client *redis.Client
+	stream string
+}
+
+// NewRedis connects to the redis:// URL.
+func NewRedis(ctx context.Context, url string, stream string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Redis URL: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	err = client.Ping(ctx).Err()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("Unable to ping Redis: %w", err)
+	}
+
+	return &Redis{client: client, stream: stream}, nil
+}
+
+// Publish adds each event as an entry with its id, type, client_id and
+// payload fields, in one round trip.
+func (r *Redis) Publish(ctx context.Context, events []repository.Event) error {
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, e := range events {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: r.stream,
+				Values: []any{
+					"id", strconv.FormatInt(e.ID, 10),
+					"type", e.Type,
+					"client_id", strconv.Itoa(e.ClientID),
+					"payload", e.Payload,
+				},
+			})
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
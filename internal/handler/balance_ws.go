@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// socketWriteWait bounds each write to a balance socket, so a client that
+// stops reading is dropped once its buffers fill up.
+const socketWriteWait = 10 * time.Second
+
+// CheckBalanceSocket answers a balance socket request that can't be served
+// with a plain HTTP error, before it is upgraded.
+func (h *Handler) CheckBalanceSocket(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	err = h.service.CheckClient(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return c.Next()
+}
+
+// StreamBalance sends the client's limite and saldo as a JSON message when
+// the socket opens and again after each write to the client. It pings every
+// streamHeartbeat and drops sockets that don't answer. A socket that falls
+// behind is closed with 1013, try again later, as are all on shutdown.
+func (h *Handler) StreamBalance(conn *websocket.Conn) {
+	id, _ := strconv.Atoi(conn.Params("id"))
+	log := slog.With("client", id)
+
+	sub, err := h.service.Subscribe(context.Background(), id)
+	if err != nil {
+		log.Error("Unable to stream balance", "error", err)
+		closeSocket(conn, websocket.CloseInternalServerErr, "")
+		return
+	}
+	defer sub.Close()
+
+	// Clients have nothing to say; reading only handles pongs and the
+	// close handshake, and notices when the client goes away.
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	})
+
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			_, _, err := conn.NextReader()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	changed := true
+	for {
+		if changed {
+			// Read back rather than taken from the update, since updates
+			// of concurrent writes may arrive out of order.
+			client, err := h.service.Balance(context.Background(), id)
+			if err != nil {
+				log.Error("Unable to stream balance", "error", err)
+				closeSocket(conn, websocket.CloseInternalServerErr, "")
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			err = conn.WriteJSON(BalanceLimitDto{Limit: client.Limit, Balance: client.Balance})
+			if err != nil {
+				return
+			}
+		}
+
+		select {
+		case _, ok := <-sub.C:
+			if !ok {
+				closeSocket(conn, websocket.CloseTryAgainLater, "")
+				return
+			}
+			// One read covers every update queued so far.
+			for len(sub.C) > 0 {
+				<-sub.C
+			}
+			changed = true
+		case <-heartbeat.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait))
+			if err != nil {
+				return
+			}
+			changed = false
+		case <-gone:
+			return
+		}
+	}
+}
+
+func closeSocket(conn *websocket.Conn, code int, text string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(socketWriteWait))
+}
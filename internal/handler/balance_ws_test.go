@@ -0,0 +1,47 @@
+package handler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/handler"
+	"github.com/fasthttp/websocket"
+)
+
+func TestStreamBalance(t *testing.T) {
+	app := newApp(t)
+	addr := serve(t, app)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/clientes/1/saldo/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var balance handler.BalanceLimitDto
+	err = conn.ReadJSON(&balance)
+	if err != nil || balance != (handler.BalanceLimitDto{Limit: 1000, Balance: 0}) {
+		t.Fatalf("got %+v, %v", balance, err)
+	}
+
+	status := do(t, app, "POST", "/clientes/1/transacoes", `{"valor": 300, "tipo": "d", "descricao": "compra"}`, nil)
+	if status != 200 {
+		t.Fatalf("got %d", status)
+	}
+
+	err = conn.ReadJSON(&balance)
+	if err != nil || balance != (handler.BalanceLimitDto{Limit: 1000, Balance: -300}) {
+		t.Fatalf("got %+v, %v", balance, err)
+	}
+
+	_, res, err := websocket.DefaultDialer.Dial("ws://"+addr+"/clientes/9/saldo/ws", nil)
+	if err == nil || res == nil || res.StatusCode != 404 {
+		t.Fatalf("unknown client: got %v, %v", res, err)
+	}
+
+	status = do(t, app, "GET", "/clientes/1/saldo/ws", "", nil)
+	if status != 426 {
+		t.Fatalf("without upgrade: got %d, want 426", status)
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateClientDto struct {
+	ID    int `json:"id" validate:"min=1"`
+	Limit int `json:"limite" validate:"min=0"`
+}
+
+type UpdateLimitDto struct {
+	Limit int `json:"limite" validate:"min=0"`
+}
+
+type ClientResponseDto struct {
+	ID      int `json:"id"`
+	Limit   int `json:"limite"`
+	Balance int `json:"saldo"`
+}
+
+func newClientResponse(c repository.Client) ClientResponseDto {
+	return ClientResponseDto{ID: c.ID, Limit: c.Limit, Balance: c.Balance}
+}
+
+func (h *Handler) CreateClient(c *fiber.Ctx) error {
+	var dto CreateClientDto
+
+	err := parseBody(c, &dto)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	client, err := h.service.CreateClient(c.UserContext(), repository.Client{ID: dto.ID, Limit: dto.Limit})
+
+	if err != nil {
+		return err
+	}
+
+	return h.send(c, 201, newClientResponse(client))
+}
+
+func (h *Handler) Client(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	client, err := h.service.Client(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return h.send(c, 200, newClientResponse(client))
+}
+
+func (h *Handler) UpdateLimit(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	var dto UpdateLimitDto
+
+	err = parseBody(c, &dto)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	client, err := h.service.UpdateLimit(c.UserContext(), id, dto.Limit)
+
+	if err != nil {
+		return err
+	}
+
+	return h.send(c, 200, BalanceLimitDto{
+		Limit:   client.Limit,
+		Balance: client.Balance,
+	})
+}
+
+func (h *Handler) DeleteClient(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	err = h.service.DeleteClient(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(204)
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requireJSON rejects POST, PUT and PATCH bodies that aren't
+// application/json with 415. BodyParser would otherwise happily decode form
+// or XML bodies too.
+func requireJSON(c *fiber.Ctx) error {
+	switch c.Method() {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch:
+	default:
+		return c.Next()
+	}
+
+	if len(c.Body()) == 0 || c.Is("json") {
+		return c.Next()
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnsupportedMediaType, c.Get(fiber.HeaderContentType))
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/csv"
+	"strconv"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+const mimeTextCSV = "text/csv"
+
+// wantsCSV reports whether the statement was asked for as CSV, either with
+// ?formato=csv or with an Accept header preferring text/csv.
+func wantsCSV(c *fiber.Ctx) bool {
+	if format := c.Query("formato"); format != "" {
+		return format == "csv"
+	}
+
+	return c.Accepts(fiber.MIMEApplicationJSON, mimeTextCSV) == mimeTextCSV
+}
+
+// sendStatementCSV writes the transactions as CSV straight into the
+// response body.
+func sendStatementCSV(c *fiber.Ctx, transactions []repository.Transaction) error {
+	c.Set(fiber.HeaderContentType, mimeTextCSV+"; charset=utf-8")
+	c.Status(200)
+
+	w := csv.NewWriter(c)
+	w.Write([]string{"valor", "tipo", "descricao", "realizada_em"})
+
+	for _, tr := range transactions {
+		w.Write([]string{
+			strconv.Itoa(tr.Amount),
+			tr.Type,
+			tr.Description,
+			tr.CreatedAt.Format(time.RFC3339Nano),
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
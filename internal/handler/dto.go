@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Amount is a valor that only decodes from a JSON integer. Plain int fields
+// would let decoders truncate or round values like 1.2.
+type Amount int
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	n, err := strconv.Atoi(string(b))
+	if err != nil {
+		return fmt.Errorf("valor must be an integer, got %s", b)
+	}
+
+	*a = Amount(n)
+	return nil
+}
+
+type CreateTransactionDto struct {
+	Value       Amount `json:"valor" validate:"min=1"`
+	Type        string `json:"tipo" validate:"oneof=c d"`
+	Description string `json:"descricao" validate:"min=1,max=10"`
+}
+
+type CreateTransferDto struct {
+	To          int    `json:"destino" validate:"min=1"`
+	Value       Amount `json:"valor" validate:"min=1"`
+	Description string `json:"descricao" validate:"min=1,max=10"`
+}
+
+type TransactionCreatedDto struct {
+	Limit     int       `json:"limite"`
+	Balance   int       `json:"saldo"`
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"realizada_em"`
+}
+
+type BalanceLimitDto struct {
+	Limit   int `json:"limite"`
+	Balance int `json:"saldo"`
+}
+
+type BalanceResponseDto struct {
+	Amount        int       `json:"total"`
+	Limit         int       `json:"limite"`
+	Available     int       `json:"saldo_disponivel"`
+	StatementDate time.Time `json:"data_extrato"`
+}
+
+type TransactionResponseDto struct {
+	Amount      int       `json:"valor"`
+	Type        string    `json:"tipo"`
+	Description string    `json:"descricao"`
+	CreatedAt   time.Time `json:"realizada_em"`
+	// BalanceAfter is only present in statements.
+	BalanceAfter *int `json:"saldo_apos,omitempty"`
+}
+
+type StatementResponseDto struct {
+	Balance            BalanceResponseDto       `json:"saldo"`
+	LatestTransactions []TransactionResponseDto `json:"ultimas_transacoes"`
+	NextPage           string                   `json:"proxima_pagina,omitempty"`
+}
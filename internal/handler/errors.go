@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/apierror"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/logging"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/peer"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/ratelimit"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/shed"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrInvalidID            = errors.New("invalid id")
+	ErrInvalidPayload       = errors.New("invalid payload")
+	ErrUnsupportedMediaType = errors.New("unsupported content type")
+	ErrNotAcceptable        = errors.New("no acceptable response format")
+	ErrUnauthorized         = errors.New("unauthorized")
+)
+
+// ErrorHandler is the app's fiber.Config.ErrorHandler. Handlers return
+// errors and this is the one place that decides their status and body.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	status, code, detail := 500, apierror.Internal, ""
+
+	var fe *fiber.Error
+	switch {
+	case errors.Is(err, ErrInvalidID):
+		status, code, detail = 422, apierror.InvalidID, err.Error()
+	case errors.Is(err, ErrInvalidPayload):
+		status, code, detail = 422, apierror.InvalidPayload, err.Error()
+	case errors.Is(err, ErrUnsupportedMediaType):
+		status, code, detail = 415, apierror.UnsupportedMediaType, "Content-Type must be application/json"
+	case errors.Is(err, ErrNotAcceptable):
+		status, code = 406, apierror.NotAcceptable
+	case errors.Is(err, ErrUnauthorized):
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		status, code = 401, apierror.Unauthorized
+	case errors.Is(err, repository.ErrClientNotFound):
+		status, code, detail = 404, apierror.ClientNotFound, err.Error()
+	case errors.Is(err, service.ErrInvalidTransaction):
+		status, code, detail = 422, apierror.InvalidTransaction, err.Error()
+	case errors.Is(err, service.ErrInvalidClient):
+		status, code, detail = 422, apierror.InvalidClient, err.Error()
+	case errors.Is(err, service.ErrInvalidQuery):
+		status, code, detail = 422, apierror.InvalidQuery, err.Error()
+	case errors.Is(err, repository.ErrTransactionNotFound):
+		status, code, detail = 404, apierror.TransactionNotFound, err.Error()
+	case errors.Is(err, repository.ErrNotReversible):
+		status, code, detail = 422, apierror.NotReversible, err.Error()
+	case errors.Is(err, repository.ErrClientExists):
+		status, code, detail = 409, apierror.ClientExists, err.Error()
+	case errors.Is(err, repository.ErrAlreadyReversed):
+		status, code, detail = 409, apierror.AlreadyReversed, err.Error()
+	case errors.Is(err, repository.ErrLimitExceeded):
+		status, code, detail = 422, apierror.LimitExceeded, err.Error()
+	case errors.Is(err, repository.ErrWebhookNotFound):
+		status, code, detail = 404, apierror.WebhookNotFound, err.Error()
+	case errors.Is(err, repository.ErrCrossShard):
+		status, code, detail = 422, apierror.CrossShard, err.Error()
+	case errors.Is(err, service.ErrStreamsUnavailable):
+		status, code, detail = 501, apierror.StreamsUnavailable, "streams are not served in this server mode"
+	case errors.Is(err, ratelimit.ErrLimited):
+		status, code, detail = 429, apierror.RateLimited, err.Error()
+	case errors.Is(err, repository.ErrBusy):
+		c.Set(fiber.HeaderRetryAfter, "1")
+		status, code, detail = 503, apierror.Busy, "try again shortly"
+	case errors.Is(err, shed.ErrOverloaded):
+		status, code, detail = 503, apierror.Busy, "try again shortly"
+	case errors.Is(err, peer.ErrUnavailable):
+		c.Set(fiber.HeaderRetryAfter, "1")
+		status, code, detail = 503, apierror.Busy, "try again shortly"
+	case errors.Is(err, context.DeadlineExceeded):
+		status, code, detail = 504, apierror.Timeout, "request took too long"
+	case errors.As(err, &fe) && fe.Code == fiber.StatusRequestEntityTooLarge:
+		status, code, detail = 413, apierror.PayloadTooLarge, fe.Message
+	case errors.As(err, &fe):
+		// Fiber's own failures, e.g. an unknown route or method.
+		status, code, detail = fe.Code, apierror.RequestFailed, fe.Message
+	}
+
+	log := logging.Request(c).With("status", status, "error", err)
+	if status >= 500 {
+		log.Error("Request failed")
+	} else {
+		log.Debug("Request rejected")
+	}
+
+	return apierror.Send(c, status, code, detail)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/logging"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportTransactions streams the client's whole history as newline-delimited
+// JSON. Rows are written to the buffered stream as they come from storage,
+// so the response is never held in memory as a whole. Like StreamTransactions,
+// each flush gets the server's write timeout, so a client that stops reading
+// can't hold the storage connection for good.
+func (h *Handler) ExportTransactions(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	err = h.service.CheckClient(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	// Behind h2c the response would be buffered whole, and the connection
+	// isn't a socket whose deadline can be extended.
+	if !h.service.Streams() {
+		return service.ErrStreamsUnavailable
+	}
+
+	encode := c.App().Config().JSONEncoder
+	writeTimeout := c.App().Config().WriteTimeout
+	conn := c.Context().Conn()
+	log := logging.Request(c)
+
+	// The server only sets a write deadline once per response, which a long
+	// export would outlive.
+	extendDeadline := func() {
+		if writeTimeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context is gone once the handler returns, so the
+		// stream runs on its own; a failed write stops it.
+		err := h.service.ExportTransactions(context.Background(), id, func(tr repository.Transaction) error {
+			line, err := encode(TransactionResponseDto{
+				Amount:      tr.Amount,
+				Type:        tr.Type,
+				Description: tr.Description,
+				CreatedAt:   tr.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+
+			line = append(line, '\n')
+			// The line won't fit, so writing it flushes the buffer.
+			if w.Available() < len(line) {
+				extendDeadline()
+			}
+
+			_, err = w.Write(line)
+			return err
+		})
+
+		if err != nil {
+			log.Error("Unable to export transactions", "error", err)
+			return
+		}
+
+		extendDeadline()
+		w.Flush()
+	})
+
+	return nil
+}
@@ -0,0 +1,406 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/encoding"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/metrics"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/pubsub"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Service interface {
+	CreateTransaction(ctx context.Context, clientID int, t repository.Transaction) (repository.Client, repository.Transaction, error)
+	Statement(ctx context.Context, clientID int, q repository.StatementQuery) (repository.Client, []repository.Transaction, error)
+	CreateTransactions(ctx context.Context, clientID int, ts []repository.Transaction) ([]repository.Client, error)
+	Transfer(ctx context.Context, fromID, toID int, t repository.Transaction) (repository.Client, error)
+	Reverse(ctx context.Context, clientID int, transactionID int64) (repository.Client, error)
+	Balance(ctx context.Context, clientID int) (repository.Client, error)
+	Transaction(ctx context.Context, clientID int, id int64) (repository.Transaction, error)
+	CheckClient(ctx context.Context, clientID int) error
+	ExportTransactions(ctx context.Context, clientID int, fn func(repository.Transaction) error) error
+	CreateClient(ctx context.Context, c repository.Client) (repository.Client, error)
+	Client(ctx context.Context, id int) (repository.Client, error)
+	UpdateLimit(ctx context.Context, id int, limit int) (repository.Client, error)
+	DeleteClient(ctx context.Context, id int) error
+	Subscribe(ctx context.Context, clientID int) (*pubsub.Subscription[repository.Client], error)
+	Streams() bool
+}
+
+type Handler struct {
+	service Service
+	// encoders are the formats responses can be negotiated into; the first
+	// one is used when the client has no preference.
+	encoders []encoding.Encoder
+}
+
+func New(s Service, encoders ...encoding.Encoder) *Handler {
+	return &Handler{service: s, encoders: encoders}
+}
+
+func (h *Handler) Register(app *fiber.App) {
+	app.Use(requireJSON)
+
+	app.Post("/clientes/:id/transacoes", h.CreateTransaction)
+	app.Get("/clientes/:id/extrato", h.Statement)
+	app.Post("/clientes/:id/transacoes/lote", h.CreateTransactions)
+	app.Get("/clientes/:id/transacoes/export", h.ExportTransactions)
+	app.Get("/clientes/:id/transacoes/stream", h.StreamTransactions)
+	app.Get("/clientes/:id/transacoes/:tid", h.Transaction)
+	app.Post("/clientes/:id/transferencias", h.Transfer)
+	app.Post("/clientes/:id/transacoes/:tid/estorno", h.Reverse)
+	app.Get("/clientes/:id/saldo", h.Balance)
+	app.Get("/clientes/:id/saldo/ws", h.CheckBalanceSocket, websocket.New(h.StreamBalance))
+	app.Post("/clientes", h.CreateClient)
+	app.Get("/clientes/:id", h.Client)
+	app.Delete("/clientes/:id", h.DeleteClient)
+	app.Patch("/clientes/:id/limite", h.UpdateLimit)
+}
+
+func (h *Handler) CreateTransaction(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	var dto CreateTransactionDto
+
+	err = parseBody(c, &dto)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	client, tr, err := h.service.CreateTransaction(c.UserContext(), id, repository.Transaction{
+		Amount:         int(dto.Value),
+		Type:           dto.Type,
+		Description:    dto.Description,
+		IdempotencyKey: c.Get("Idempotency-Key"),
+	})
+
+	switch {
+	case errors.Is(err, repository.ErrLimitExceeded):
+		metrics.LimitRejected()
+	case errors.Is(err, repository.ErrClientNotFound):
+		metrics.ClientNotFound()
+	case err == nil:
+		metrics.Transaction(tr.Type, tr.Amount)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.Location(fmt.Sprintf("/clientes/%d/transacoes/%d", id, tr.ID))
+
+	return h.send(c, 200, TransactionCreatedDto{
+		Limit:     client.Limit,
+		Balance:   client.Balance,
+		ID:        tr.ID,
+		CreatedAt: tr.CreatedAt,
+	})
+}
+
+func (h *Handler) Statement(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	q, err := parseStatementQuery(c)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", service.ErrInvalidQuery, err)
+	}
+
+	if format := c.Query("formato"); format != "" && format != "json" && format != "csv" {
+		return fmt.Errorf("%w: formato must be json or csv, got %q", service.ErrInvalidQuery, format)
+	}
+
+	client, transactions, err := h.service.Statement(c.UserContext(), id, q)
+
+	if err != nil {
+		return err
+	}
+
+	format := mimeTextCSV
+	if !wantsCSV(c) {
+		enc, ok := h.negotiate(c)
+		if !ok {
+			return ErrNotAcceptable
+		}
+		format = enc.ContentType()
+	}
+
+	c.Set(fiber.HeaderETag, statementETag(client, format))
+	if c.Fresh() {
+		return c.SendStatus(304)
+	}
+
+	if format == mimeTextCSV {
+		return sendStatementCSV(c, transactions)
+	}
+
+	res := NewStatementResponse(client, transactions)
+
+	if len(transactions) == q.Limit {
+		res.NextPage = nextPageURL(c, transactions[len(transactions)-1].ID)
+	}
+
+	return h.send(c, 200, res)
+}
+
+// NewStatementResponse builds a statement without its next page, which
+// depends on the API serving it.
+func NewStatementResponse(client repository.Client, transactions []repository.Transaction) StatementResponseDto {
+	res := StatementResponseDto{
+		Balance: BalanceResponseDto{
+			Amount:        client.Balance,
+			Limit:         client.Limit,
+			Available:     client.Limit + client.Balance,
+			StatementDate: time.Now(),
+		},
+		LatestTransactions: make([]TransactionResponseDto, 0, len(transactions)),
+	}
+
+	for i, tr := range transactions {
+		res.LatestTransactions = append(res.LatestTransactions, TransactionResponseDto{
+			Amount:       tr.Amount,
+			Type:         tr.Type,
+			Description:  tr.Description,
+			CreatedAt:    tr.CreatedAt,
+			BalanceAfter: &transactions[i].BalanceAfter,
+		})
+	}
+
+	return res
+}
+
+// statementETag changes whenever a transaction is added, the balance or
+// limit move, or the response format differs. It is weak since data_extrato
+// differs on every response.
+func statementETag(client repository.Client, contentType string) string {
+	return fmt.Sprintf(`W/"%d-%d-%d-%s"`, client.LastTransactionID, client.Balance, client.Limit, contentType)
+}
+
+// nextPageURL points at the page after the one ending at lastID, keeping the
+// current filters and replacing offset pagination with the antes_de cursor.
+func nextPageURL(c *fiber.Ctx, lastID int64) string {
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	query.Del("pagina")
+	query.Set("antes_de", strconv.FormatInt(lastID, 10))
+
+	return c.Path() + "?" + query.Encode()
+}
+
+// CreateTransactions applies a batch atomically. A failed batch is answered
+// like a single failed transaction, its detalhe naming the item at fault.
+func (h *Handler) CreateTransactions(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	var dtos []CreateTransactionDto
+
+	err = parseBody(c, &dtos)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	ts := make([]repository.Transaction, 0, len(dtos))
+	for _, dto := range dtos {
+		ts = append(ts, repository.Transaction{
+			Amount:      int(dto.Value),
+			Type:        dto.Type,
+			Description: dto.Description,
+		})
+	}
+
+	clients, err := h.service.CreateTransactions(c.UserContext(), id, ts)
+
+	switch {
+	case errors.Is(err, repository.ErrLimitExceeded):
+		metrics.LimitRejected()
+	case errors.Is(err, repository.ErrClientNotFound):
+		metrics.ClientNotFound()
+	case err == nil:
+		for _, t := range ts {
+			metrics.Transaction(t.Type, t.Amount)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	res := make([]BalanceLimitDto, len(clients))
+	for i, client := range clients {
+		res[i] = BalanceLimitDto{Limit: client.Limit, Balance: client.Balance}
+	}
+
+	return h.send(c, 200, res)
+}
+
+func (h *Handler) Transfer(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	var dto CreateTransferDto
+
+	err = parseBody(c, &dto)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	client, err := h.service.Transfer(c.UserContext(), id, dto.To, repository.Transaction{
+		Amount:      int(dto.Value),
+		Description: dto.Description,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return h.send(c, 200, BalanceLimitDto{
+		Limit:   client.Limit,
+		Balance: client.Balance,
+	})
+}
+
+func (h *Handler) Transaction(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	tid, err := strconv.ParseInt(c.Params("tid"), 10, 64)
+
+	if err != nil {
+		return fmt.Errorf("%w: tid must be an integer, got %q", ErrInvalidID, c.Params("tid"))
+	}
+
+	tr, err := h.service.Transaction(c.UserContext(), id, tid)
+
+	if err != nil {
+		return err
+	}
+
+	return h.send(c, 200, TransactionResponseDto{
+		Amount:      tr.Amount,
+		Type:        tr.Type,
+		Description: tr.Description,
+		CreatedAt:   tr.CreatedAt,
+	})
+}
+
+func (h *Handler) Reverse(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	tid, err := strconv.ParseInt(c.Params("tid"), 10, 64)
+
+	if err != nil {
+		return fmt.Errorf("%w: tid must be an integer, got %q", ErrInvalidID, c.Params("tid"))
+	}
+
+	client, err := h.service.Reverse(c.UserContext(), id, tid)
+
+	if err != nil {
+		return err
+	}
+
+	return h.send(c, 200, BalanceLimitDto{
+		Limit:   client.Limit,
+		Balance: client.Balance,
+	})
+}
+
+// parseStatementQuery reads the ?limite= and ?pagina= params, defaulting to
+// the first page of 10 transactions, the ?antes_de= cursor, the ?de= and
+// ?ate= dates and the ?tipo= filter. Both dates are inclusive, so ate covers
+// that whole day.
+func parseStatementQuery(c *fiber.Ctx) (repository.StatementQuery, error) {
+	limit, err := strconv.Atoi(c.Query("limite", "10"))
+	if err != nil {
+		return repository.StatementQuery{}, fmt.Errorf("Invalid query limite (%s) %v", c.Query("limite"), err)
+	}
+
+	page, err := strconv.Atoi(c.Query("pagina", "1"))
+	if err != nil {
+		return repository.StatementQuery{}, fmt.Errorf("Invalid query pagina (%s) %v", c.Query("pagina"), err)
+	}
+
+	q := repository.StatementQuery{
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+
+	if from := c.Query("de"); from != "" {
+		q.From, err = time.Parse(time.DateOnly, from)
+		if err != nil {
+			return repository.StatementQuery{}, fmt.Errorf("Invalid query de (%s) %v", from, err)
+		}
+	}
+
+	if to := c.Query("ate"); to != "" {
+		q.To, err = time.Parse(time.DateOnly, to)
+		if err != nil {
+			return repository.StatementQuery{}, fmt.Errorf("Invalid query ate (%s) %v", to, err)
+		}
+		q.To = q.To.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	if before := c.Query("antes_de"); before != "" {
+		q.Before, err = strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return repository.StatementQuery{}, fmt.Errorf("Invalid query antes_de (%s) %v", before, err)
+		}
+	}
+
+	q.Type = c.Query("tipo")
+	if q.Type != "" && q.Type != "c" && q.Type != "d" {
+		return repository.StatementQuery{}, fmt.Errorf("Invalid query tipo: %s", q.Type)
+	}
+
+	return q, nil
+}
+
+func (h *Handler) Balance(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	client, err := h.service.Balance(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return h.send(c, 200, BalanceLimitDto{
+		Limit:   client.Limit,
+		Balance: client.Balance,
+	})
+}
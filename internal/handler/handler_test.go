@@ -0,0 +1,317 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/apierror"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/encoding"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/handler"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/pubsub"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newApp serves the handlers, streams included, over a Memory repository
+// holding client 1, with limite 1000, and client 2, with 500.
+func newApp(t *testing.T) *fiber.App {
+	t.Helper()
+
+	repo := repository.NewMemory([]repository.Client{{ID: 1, Limit: 1000}, {ID: 2, Limit: 500}})
+	svc := service.New(repo, repo)
+	svc.UseHub(pubsub.New[repository.Client](8))
+	err := svc.RefreshClients(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: handler.ErrorHandler})
+	handler.New(svc, encoding.JSON(json.Marshal)).Register(app)
+
+	return app
+}
+
+// do sends the request and decodes the JSON response into out, when not
+// nil, returning the status.
+func do(t *testing.T, app *fiber.App, method, target, body string, out any, headers ...string) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	res, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != nil {
+		err = json.Unmarshal(data, out)
+		if err != nil {
+			t.Fatalf("decoding %s: %v", data, err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+func TestCreateTransaction(t *testing.T) {
+	app := newApp(t)
+
+	var created handler.TransactionCreatedDto
+	status := do(t, app, "POST", "/clientes/1/transacoes", `{"valor": 900, "tipo": "d", "descricao": "compra"}`, &created)
+	if status != 200 || created.Balance != -900 || created.Limit != 1000 {
+		t.Fatalf("got %d %+v", status, created)
+	}
+
+	var apiErr apierror.Error
+	status = do(t, app, "POST", "/clientes/2/transacoes", `{"valor": 501, "tipo": "d", "descricao": "compra"}`, &apiErr)
+	if status != 422 || apiErr.Code != apierror.LimitExceeded {
+		t.Fatalf("got %d %+v, want 422 %s", status, apiErr, apierror.LimitExceeded)
+	}
+
+	status = do(t, app, "POST", "/clientes/9/transacoes", `{"valor": 1, "tipo": "c", "descricao": "pix"}`, &apiErr)
+	if status != 404 || apiErr.Code != apierror.ClientNotFound {
+		t.Fatalf("got %d %+v, want 404 %s", status, apiErr, apierror.ClientNotFound)
+	}
+
+	for _, body := range []string{
+		`{"valor": 1.5, "tipo": "c", "descricao": "pix"}`,
+		`{"valor": 1, "tipo": "x", "descricao": "pix"}`,
+		`{"valor": 1, "tipo": "c", "descricao": "descricao longa"}`,
+		`{"valor": 1, "tipo": "c"}`,
+	} {
+		status = do(t, app, "POST", "/clientes/1/transacoes", body, nil)
+		if status != 422 {
+			t.Fatalf("%s got %d, want 422", body, status)
+		}
+	}
+
+	status = do(t, app, "POST", "/clientes/x/transacoes", `{"valor": 1, "tipo": "c", "descricao": "pix"}`, &apiErr)
+	if status != 422 || apiErr.Code != apierror.InvalidID {
+		t.Fatalf("got %d %+v, want 422 %s", status, apiErr, apierror.InvalidID)
+	}
+}
+
+func TestCreateTransactions(t *testing.T) {
+	app := newApp(t)
+
+	var balances []handler.BalanceLimitDto
+	status := do(t, app, "POST", "/clientes/2/transacoes/lote", `[{"valor": 100, "tipo": "c", "descricao": "a"}, {"valor": 300, "tipo": "d", "descricao": "b"}]`, &balances)
+	if status != 200 || len(balances) != 2 || balances[0].Balance != 100 || balances[1].Balance != -200 {
+		t.Fatalf("got %d %+v", status, balances)
+	}
+
+	var apiErr apierror.Error
+	status = do(t, app, "POST", "/clientes/2/transacoes/lote", `[{"valor": 1, "tipo": "d", "descricao": "a"}, {"valor": 300, "tipo": "d", "descricao": "b"}]`, &apiErr)
+	if status != 422 || apiErr.Code != apierror.LimitExceeded || !strings.HasPrefix(apiErr.Detail, "item 1:") {
+		t.Fatalf("got %d %+v, want 422 %s for item 1", status, apiErr, apierror.LimitExceeded)
+	}
+
+	status = do(t, app, "POST", "/clientes/2/transacoes/lote", `[{"valor": 1, "tipo": "d", "descricao": "a"}, {"valor": 0, "tipo": "d", "descricao": "b"}]`, &apiErr)
+	if status != 422 || apiErr.Code != apierror.InvalidPayload || !strings.Contains(apiErr.Detail, "[1].valor") {
+		t.Fatalf("got %d %+v, want 422 %s naming [1].valor", status, apiErr, apierror.InvalidPayload)
+	}
+
+	var balance handler.BalanceLimitDto
+	do(t, app, "GET", "/clientes/2/saldo", "", &balance)
+	if balance.Balance != -200 {
+		t.Fatalf("got saldo %d, want -200", balance.Balance)
+	}
+
+	status = do(t, app, "POST", "/clientes/2/transacoes/lote", `[{"valor": 1, "tipo": "c", "descricao": "a"}]`, nil, "Accept", "application/xml")
+	if status != 406 {
+		t.Fatalf("got %d, want 406", status)
+	}
+}
+
+func TestCreateTransactionIdempotencyKey(t *testing.T) {
+	app := newApp(t)
+	body := `{"valor": 100, "tipo": "c", "descricao": "pix"}`
+
+	var first, second handler.TransactionCreatedDto
+	do(t, app, "POST", "/clientes/1/transacoes", body, &first, "Idempotency-Key", "abc")
+	status := do(t, app, "POST", "/clientes/1/transacoes", body, &second, "Idempotency-Key", "abc")
+	if status != 200 || second.ID != first.ID || second.Balance != 100 {
+		t.Fatalf("replay got %d %+v, want %+v", status, second, first)
+	}
+
+	var balance handler.BalanceLimitDto
+	do(t, app, "GET", "/clientes/1/saldo", "", &balance)
+	if balance.Balance != 100 {
+		t.Fatalf("got saldo %d, want 100", balance.Balance)
+	}
+}
+
+func TestStatement(t *testing.T) {
+	app := newApp(t)
+
+	for _, body := range []string{
+		`{"valor": 100, "tipo": "c", "descricao": "a"}`,
+		`{"valor": 30, "tipo": "d", "descricao": "b"}`,
+		`{"valor": 50, "tipo": "c", "descricao": "c"}`,
+	} {
+		status := do(t, app, "POST", "/clientes/1/transacoes", body, nil)
+		if status != 200 {
+			t.Fatalf("%s got %d", body, status)
+		}
+	}
+
+	var statement handler.StatementResponseDto
+	status := do(t, app, "GET", "/clientes/1/extrato?limite=2", "", &statement)
+	if status != 200 || statement.Balance.Amount != 120 || statement.Balance.Limit != 1000 {
+		t.Fatalf("got %d %+v", status, statement.Balance)
+	}
+
+	ts := statement.LatestTransactions
+	if len(ts) != 2 || ts[0].Description != "c" || *ts[0].BalanceAfter != 120 || ts[1].Description != "b" || *ts[1].BalanceAfter != 70 {
+		t.Fatalf("got %+v", ts)
+	}
+	if statement.NextPage == "" {
+		t.Fatal("full page without proxima_pagina")
+	}
+
+	status = do(t, app, "GET", statement.NextPage, "", &statement)
+	if status != 200 || len(statement.LatestTransactions) != 1 || statement.LatestTransactions[0].Description != "a" {
+		t.Fatalf("got %d %+v", status, statement.LatestTransactions)
+	}
+
+	status = do(t, app, "GET", "/clientes/9/extrato", "", nil)
+	if status != 404 {
+		t.Fatalf("got %d, want 404", status)
+	}
+}
+
+func TestTransferAndReverse(t *testing.T) {
+	app := newApp(t)
+
+	var balance handler.BalanceLimitDto
+	status := do(t, app, "POST", "/clientes/1/transferencias", `{"destino": 2, "valor": 300, "descricao": "pix"}`, &balance)
+	if status != 200 || balance.Balance != -300 {
+		t.Fatalf("got %d %+v", status, balance)
+	}
+
+	do(t, app, "GET", "/clientes/2/saldo", "", &balance)
+	if balance.Balance != 300 {
+		t.Fatalf("recipient has saldo %d, want 300", balance.Balance)
+	}
+
+	var created handler.TransactionCreatedDto
+	do(t, app, "POST", "/clientes/2/transacoes", `{"valor": 100, "tipo": "d", "descricao": "compra"}`, &created)
+
+	target := "/clientes/2/transacoes/" + strconv.FormatInt(created.ID, 10) + "/estorno"
+	status = do(t, app, "POST", target, "", &balance)
+	if status != 200 || balance.Balance != 300 {
+		t.Fatalf("got %d %+v", status, balance)
+	}
+
+	var apiErr apierror.Error
+	status = do(t, app, "POST", target, "", &apiErr)
+	if status != 409 || apiErr.Code != apierror.AlreadyReversed {
+		t.Fatalf("got %d %+v, want 409 %s", status, apiErr, apierror.AlreadyReversed)
+	}
+}
+
+func TestExportTransactions(t *testing.T) {
+	app := newApp(t)
+
+	// Enough lines to flush the stream's buffer more than once.
+	for i := 0; i < 500; i++ {
+		status := do(t, app, "POST", "/clientes/1/transacoes", `{"valor": 1, "tipo": "c", "descricao": "pix"}`, nil)
+		if status != 200 {
+			t.Fatalf("got %d", status)
+		}
+	}
+
+	res, err := app.Test(httptest.NewRequest("GET", "/clientes/1/transacoes/export", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if res.StatusCode != 200 || len(lines) != 500 {
+		t.Fatalf("got %d with %d lines", res.StatusCode, len(lines))
+	}
+
+	var tr handler.TransactionResponseDto
+	err = json.Unmarshal([]byte(lines[len(lines)-1]), &tr)
+	if err != nil || tr.Amount != 1 || tr.Description != "pix" {
+		t.Fatalf("got %+v, %v", tr, err)
+	}
+}
+
+func TestClients(t *testing.T) {
+	app := newApp(t)
+
+	var created handler.ClientResponseDto
+	status := do(t, app, "POST", "/clientes", `{"id": 3, "limite": 100}`, &created)
+	if status != 201 || created != (handler.ClientResponseDto{ID: 3, Limit: 100}) {
+		t.Fatalf("got %d %+v", status, created)
+	}
+
+	var client handler.ClientResponseDto
+	status = do(t, app, "GET", "/clientes/3", "", &client)
+	if status != 200 || client != created {
+		t.Fatalf("got %d %+v", status, client)
+	}
+
+	var apiErr apierror.Error
+	status = do(t, app, "GET", "/clientes/3", "", &apiErr, "Accept", "application/xml")
+	if status != 406 || apiErr.Code != apierror.NotAcceptable {
+		t.Fatalf("got %d %+v, want 406", status, apiErr)
+	}
+}
+
+func TestNegotiation(t *testing.T) {
+	repo := repository.NewMemory([]repository.Client{{ID: 1, Limit: 1000}})
+	svc := service.New(repo, repo)
+	err := svc.RefreshClients(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: handler.ErrorHandler})
+	handler.New(svc, encoding.JSON(json.Marshal), encoding.Protobuf(), encoding.MessagePack()).Register(app)
+
+	for accept, want := range map[string]string{
+		"":                                  "application/json",
+		"application/x-protobuf":            "application/x-protobuf",
+		"application/x-protobuf;q=0.5, */*": "application/json",
+		"application/msgpack":               "application/msgpack",
+	} {
+		req := httptest.NewRequest("GET", "/clientes/1/saldo", nil)
+		req.Header.Set("Accept", accept)
+
+		res, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if res.StatusCode != 200 || res.Header.Get(fiber.HeaderContentType) != want {
+			t.Fatalf("Accept %q: got %d %s, want %s", accept, res.StatusCode, res.Header.Get(fiber.HeaderContentType), want)
+		}
+	}
+}
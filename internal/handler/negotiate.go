@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/encoding"
+	"github.com/gofiber/fiber/v2"
+)
+
+// negotiate picks the encoder matching the Accept header.
+func (h *Handler) negotiate(c *fiber.Ctx) (encoding.Encoder, bool) {
+	offers := make([]string, len(h.encoders))
+	for i, enc := range h.encoders {
+		offers[i] = enc.ContentType()
+	}
+
+	accepted := c.Accepts(offers...)
+	for _, enc := range h.encoders {
+		if enc.ContentType() == accepted {
+			return enc, true
+		}
+	}
+
+	return nil, false
+}
+
+// send writes v with the negotiated encoder, or 406 when the client accepts
+// none of them.
+func (h *Handler) send(c *fiber.Ctx, status int, v any) error {
+	enc, ok := h.negotiate(c)
+	if !ok {
+		return ErrNotAcceptable
+	}
+
+	body, err := enc.Encode(v)
+	if err != nil {
+		return fmt.Errorf("Unable to encode response as %s %w", enc.ContentType(), err)
+	}
+
+	c.Set(fiber.HeaderContentType, enc.ContentType())
+	return c.Status(status).Send(body)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import "github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/encoding"
+
+// AppendProto methods encode the DTOs as the messages of proto/rinha.proto.
+// Field numbers must be kept in sync with that file.
+
+func (d TransactionCreatedDto) AppendProto(b []byte) []byte {
+	b = encoding.AppendInt(b, 1, int64(d.Limit))
+	b = encoding.AppendSint(b, 2, int64(d.Balance))
+	b = encoding.AppendInt(b, 3, d.ID)
+	return encoding.AppendTimestamp(b, 4, d.CreatedAt)
+}
+
+func (d BalanceLimitDto) AppendProto(b []byte) []byte {
+	b = encoding.AppendInt(b, 1, int64(d.Limit))
+	return encoding.AppendSint(b, 2, int64(d.Balance))
+}
+
+func (d ClientResponseDto) AppendProto(b []byte) []byte {
+	b = encoding.AppendInt(b, 1, int64(d.ID))
+	b = encoding.AppendInt(b, 2, int64(d.Limit))
+	return encoding.AppendSint(b, 3, int64(d.Balance))
+}
+
+func (d BalanceResponseDto) AppendProto(b []byte) []byte {
+	b = encoding.AppendSint(b, 1, int64(d.Amount))
+	b = encoding.AppendInt(b, 2, int64(d.Limit))
+	b = encoding.AppendSint(b, 3, int64(d.Available))
+	return encoding.AppendTimestamp(b, 4, d.StatementDate)
+}
+
+func (d TransactionResponseDto) AppendProto(b []byte) []byte {
+	b = encoding.AppendInt(b, 1, int64(d.Amount))
+	b = encoding.AppendString(b, 2, d.Type)
+	b = encoding.AppendString(b, 3, d.Description)
+	b = encoding.AppendTimestamp(b, 4, d.CreatedAt)
+	if d.BalanceAfter != nil {
+		b = encoding.AppendSintAlways(b, 5, int64(*d.BalanceAfter))
+	}
+
+	return b
+}
+
+func (d StatementResponseDto) AppendProto(b []byte) []byte {
+	b = encoding.AppendMessage(b, 1, d.Balance)
+	for _, tr := range d.LatestTransactions {
+		b = encoding.AppendMessage(b, 2, tr)
+	}
+
+	return encoding.AppendString(b, 3, d.NextPage)
+}
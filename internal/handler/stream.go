@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/logging"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment, so proxies
+// keep it open and a gone client is noticed.
+const streamHeartbeat = 15 * time.Second
+
+// maxStreamCatchUp caps how many missed transactions a stream sends at
+// once; older ones are skipped.
+const maxStreamCatchUp = 1000
+
+type TransactionEventDto struct {
+	ID          int64     `json:"id"`
+	Amount      int       `json:"valor"`
+	Type        string    `json:"tipo"`
+	Description string    `json:"descricao"`
+	CreatedAt   time.Time `json:"realizada_em"`
+	// Balance is the client's saldo right after the transaction.
+	Balance int `json:"saldo"`
+}
+
+// StreamTransactions keeps the connection open and sends each new
+// transaction of the client as a Server-Sent Event named transacao, with
+// the transaction id as the event id. A client reconnecting with
+// Last-Event-ID first gets the ones it missed. Only writes served by this
+// process are seen, so with peers the request must Accept
+// text/event-stream to be relayed from the client's owner.
+func (h *Handler) StreamTransactions(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	var lastID int64
+	if last := c.Get("Last-Event-ID"); last != "" {
+		lastID, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || lastID < 0 {
+			return fmt.Errorf("%w: Last-Event-ID must be a transaction id, got %q", service.ErrInvalidQuery, last)
+		}
+	}
+
+	// Subscribed before looking for the newest transaction, so none is
+	// missed in between.
+	sub, err := h.service.Subscribe(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	if c.Get("Last-Event-ID") == "" {
+		_, latest, err := h.service.Statement(c.UserContext(), id, repository.StatementQuery{Limit: 1})
+		if err != nil {
+			sub.Close()
+			return err
+		}
+
+		if len(latest) > 0 {
+			lastID = latest[0].ID
+		}
+	}
+
+	encode := c.App().Config().JSONEncoder
+	writeTimeout := c.App().Config().WriteTimeout
+	conn := c.Context().Conn()
+	log := logging.Request(c)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	// Stops nginx from buffering the events.
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		changed := true
+		for {
+			if changed {
+				ts, err := h.transactionsAfter(context.Background(), id, lastID)
+				if err != nil {
+					log.Error("Unable to stream transactions", "error", err)
+					return
+				}
+
+				for _, tr := range ts {
+					data, err := encode(TransactionEventDto{
+						ID:          tr.ID,
+						Amount:      tr.Amount,
+						Type:        tr.Type,
+						Description: tr.Description,
+						CreatedAt:   tr.CreatedAt,
+						Balance:     tr.BalanceAfter,
+					})
+					if err != nil {
+						log.Error("Unable to stream transactions", "error", err)
+						return
+					}
+
+					fmt.Fprintf(w, "event: transacao\nid: %d\ndata: %s\n\n", tr.ID, data)
+					lastID = tr.ID
+				}
+			} else {
+				w.WriteString(": heartbeat\n\n")
+			}
+
+			// The server only sets a write deadline once per response,
+			// which a long stream would outlive.
+			if writeTimeout > 0 {
+				conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			}
+
+			// A failed flush means the client went away.
+			if w.Flush() != nil {
+				return
+			}
+
+			select {
+			case _, ok := <-sub.C:
+				// Closed when the stream fell behind or on shutdown; the
+				// client reconnects and catches up.
+				if !ok {
+					return
+				}
+				// One look at storage covers every update queued so far.
+				for len(sub.C) > 0 {
+					<-sub.C
+				}
+				changed = true
+			case <-heartbeat.C:
+				changed = false
+			}
+		}
+	})
+
+	return nil
+}
+
+// transactionsAfter returns the client's transactions with an id greater
+// than lastID, oldest first, keeping the newest maxStreamCatchUp.
+func (h *Handler) transactionsAfter(ctx context.Context, clientID int, lastID int64) ([]repository.Transaction, error) {
+	var ts []repository.Transaction
+
+	q := repository.StatementQuery{Limit: service.MaxStatementLimit}
+	for len(ts) < maxStreamCatchUp {
+		_, page, err := h.service.Statement(ctx, clientID, q)
+		if err != nil {
+			return nil, err
+		}
+
+		i := slices.IndexFunc(page, func(t repository.Transaction) bool { return t.ID <= lastID })
+		if i >= 0 {
+			ts = append(ts, page[:i]...)
+			break
+		}
+
+		ts = append(ts, page...)
+		if len(page) < q.Limit {
+			break
+		}
+		q.Before = page[len(page)-1].ID
+	}
+
+	slices.Reverse(ts)
+
+	return ts, nil
+}
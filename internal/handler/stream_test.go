@@ -0,0 +1,116 @@
+package handler_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/apierror"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/handler"
+	"github.com/gofiber/fiber/v2"
+)
+
+// serve listens for app on a local port, since streams don't end for
+// app.Test to return, and returns the address.
+func serve(t *testing.T, app *fiber.App) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.ShutdownWithTimeout(time.Second) })
+
+	return ln.Addr().String()
+}
+
+// readEvent reads the next Server-Sent Event, skipping heartbeats.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+
+	event := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(event) > 0 {
+				return event
+			}
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ": ")
+		if field != "" {
+			event[field] = value
+		}
+	}
+}
+
+func TestStreamTransactions(t *testing.T) {
+	app := newApp(t)
+	addr := serve(t, app)
+
+	status := do(t, app, "POST", "/clientes/1/transacoes", `{"valor": 10, "tipo": "c", "descricao": "antes"}`, nil)
+	if status != 200 {
+		t.Fatalf("got %d", status)
+	}
+
+	// Reconnecting from the start, the stream catches up first.
+	req, err := http.NewRequest("GET", "http://"+addr+"/clientes/1/transacoes/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", "0")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 || res.Header.Get(fiber.HeaderContentType) != "text/event-stream" {
+		t.Fatalf("got %d %s", res.StatusCode, res.Header.Get(fiber.HeaderContentType))
+	}
+
+	events := bufio.NewReader(res.Body)
+	expect := func(id, description string, balance int) {
+		t.Helper()
+		event := readEvent(t, events)
+
+		var data handler.TransactionEventDto
+		err := json.Unmarshal([]byte(event["data"]), &data)
+		if err != nil || event["event"] != "transacao" || event["id"] != id ||
+			data.Description != description || data.Balance != balance {
+			t.Fatalf("got %v, %v", event, err)
+		}
+	}
+
+	expect("1", "antes", 10)
+
+	status = do(t, app, "POST", "/clientes/1/transacoes", `{"valor": 5, "tipo": "d", "descricao": "depois"}`, nil)
+	if status != 200 {
+		t.Fatalf("got %d", status)
+	}
+
+	expect("2", "depois", 5)
+
+	var apiErr apierror.Error
+	status = do(t, app, "GET", "/clientes/1/transacoes/stream", "", &apiErr, "Last-Event-ID", "x")
+	if status != 422 || apiErr.Code != apierror.InvalidQuery {
+		t.Fatalf("got %d %+v", status, apiErr)
+	}
+
+	status = do(t, app, "GET", "/clientes/9/transacoes/stream", "", &apiErr)
+	if status != 404 {
+		t.Fatalf("got %d %+v", status, apiErr)
+	}
+}
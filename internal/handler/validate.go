@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their json name, which is what clients send.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		return name
+	})
+
+	return v
+}
+
+// parseBody decodes the request body into dto and checks its validate tags,
+// those of each item when dto points to a slice.
+func parseBody(c *fiber.Ctx, dto any) error {
+	err := c.BodyParser(dto)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(dto))
+	items := v.Kind() == reflect.Slice
+	if items {
+		err = validate.Var(v.Interface(), "dive")
+	} else {
+		err = validate.Struct(dto)
+	}
+
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			rule := e.Tag()
+			if e.Param() != "" {
+				rule += "=" + e.Param()
+			}
+			field := e.Field()
+			if items {
+				// Named with the item's index, e.g. [0].valor.
+				field = e.Namespace()
+			}
+			msgs[i] = fmt.Sprintf("%s failed %s", field, rule)
+		}
+		return errors.New(strings.Join(msgs, ", "))
+	}
+
+	return err
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/webhook"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxDeliveries caps the limite of a delivery listing.
+const maxDeliveries = 500
+
+// deliveryStatuses maps the status query and response values to the
+// stored ones.
+var deliveryStatuses = map[string]string{
+	"pendente": repository.DeliveryPending,
+	"entregue": repository.DeliveryDelivered,
+	"falhou":   repository.DeliveryFailed,
+}
+
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, w repository.Webhook) (repository.Webhook, error)
+	Webhooks(ctx context.Context) ([]repository.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	Deliveries(ctx context.Context, webhookID int64, status string, limit int) ([]repository.Delivery, error)
+}
+
+type CreateWebhookDto struct {
+	URL string `json:"url" validate:"required,http_url,max=2048"`
+	// ClientID, when set, limits the webhook to one client's events.
+	ClientID int `json:"cliente_id" validate:"min=0"`
+}
+
+type WebhookDto struct {
+	ID       int64  `json:"id"`
+	URL      string `json:"url"`
+	ClientID *int   `json:"cliente_id"`
+	// Secret signs the deliveries. It is only shown when created.
+	Secret    string    `json:"segredo,omitempty"`
+	CreatedAt time.Time `json:"criado_em"`
+}
+
+type DeliveryDto struct {
+	ID            int64      `json:"id"`
+	Event         string     `json:"evento"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"tentativas"`
+	NextAttemptAt *time.Time `json:"proxima_tentativa_em,omitempty"`
+	LastStatus    int        `json:"ultimo_status,omitempty"`
+	LastError     string     `json:"ultimo_erro,omitempty"`
+	CreatedAt     time.Time  `json:"criada_em"`
+	DeliveredAt   *time.Time `json:"entregue_em,omitempty"`
+}
+
+func newWebhookDto(w repository.Webhook) WebhookDto {
+	dto := WebhookDto{ID: w.ID, URL: w.URL, CreatedAt: w.CreatedAt}
+	if w.ClientID != 0 {
+		dto.ClientID = &w.ClientID
+	}
+
+	return dto
+}
+
+func newDeliveryDto(d repository.Delivery) DeliveryDto {
+	dto := DeliveryDto{
+		ID:         d.ID,
+		Event:      d.Type,
+		Attempts:   d.Attempts,
+		LastStatus: d.LastStatus,
+		LastError:  d.LastError,
+		CreatedAt:  d.CreatedAt,
+	}
+
+	for name, status := range deliveryStatuses {
+		if status == d.Status {
+			dto.Status = name
+		}
+	}
+
+	switch d.Status {
+	case repository.DeliveryPending:
+		dto.NextAttemptAt = &d.NextAttemptAt
+	case repository.DeliveryDelivered:
+		dto.DeliveredAt = &d.DeliveredAt
+	}
+
+	return dto
+}
+
+// Webhooks serves the registration of webhooks and the status of their
+// deliveries, to callers sending token as a bearer token.
+type Webhooks struct {
+	store WebhookStore
+	token string
+}
+
+func NewWebhooks(store WebhookStore, token string) *Webhooks {
+	return &Webhooks{store: store, token: token}
+}
+
+func (h *Webhooks) Register(app *fiber.App) {
+	app.Post("/webhooks", h.authorize, h.Create)
+	app.Get("/webhooks", h.authorize, h.List)
+	app.Delete("/webhooks/:id", h.authorize, h.Delete)
+	app.Get("/webhooks/:id/entregas", h.authorize, h.Deliveries)
+}
+
+func (h *Webhooks) authorize(c *fiber.Ctx) error {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		return ErrUnauthorized
+	}
+
+	return c.Next()
+}
+
+func (h *Webhooks) Create(c *fiber.Ctx) error {
+	var dto CreateWebhookDto
+
+	err := parseBody(c, &dto)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	err = webhook.CheckURL(dto.URL)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	secret, err := webhook.NewSecret()
+
+	if err != nil {
+		return err
+	}
+
+	w, err := h.store.CreateWebhook(c.UserContext(), repository.Webhook{
+		ClientID: dto.ClientID,
+		URL:      dto.URL,
+		Secret:   secret,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	res := newWebhookDto(w)
+	res.Secret = w.Secret
+
+	return c.Status(201).JSON(res)
+}
+
+func (h *Webhooks) List(c *fiber.Ctx) error {
+	webhooks, err := h.store.Webhooks(c.UserContext())
+
+	if err != nil {
+		return err
+	}
+
+	res := make([]WebhookDto, len(webhooks))
+	for i, w := range webhooks {
+		res[i] = newWebhookDto(w)
+	}
+
+	return c.Status(200).JSON(res)
+}
+
+func (h *Webhooks) Delete(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	err = h.store.DeleteWebhook(c.UserContext(), id)
+
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(204)
+}
+
+// Deliveries lists the webhook's newest deliveries, up to limite (50 by
+// default), only those with the given status when set.
+func (h *Webhooks) Deliveries(c *fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+
+	if err != nil {
+		return fmt.Errorf("%w: id must be an integer, got %q", ErrInvalidID, c.Params("id"))
+	}
+
+	limit, err := strconv.Atoi(c.Query("limite", "50"))
+
+	if err != nil || limit < 1 || limit > maxDeliveries {
+		return fmt.Errorf("%w: limite must be between 1 and %d", service.ErrInvalidQuery, maxDeliveries)
+	}
+
+	var status string
+	if name := c.Query("status"); name != "" {
+		var ok bool
+		status, ok = deliveryStatuses[name]
+		if !ok {
+			return fmt.Errorf("%w: status must be pendente, entregue or falhou, got %q", service.ErrInvalidQuery, name)
+		}
+	}
+
+	deliveries, err := h.store.Deliveries(c.UserContext(), id, status, limit)
+
+	if err != nil {
+		return err
+	}
+
+	res := make([]DeliveryDto, len(deliveries))
+	for i, d := range deliveries {
+		res[i] = newDeliveryDto(d)
+	}
+
+	return c.Status(200).JSON(res)
+}
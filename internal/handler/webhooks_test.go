@@ -0,0 +1,64 @@
+package handler_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/apierror"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/handler"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/gofiber/fiber/v2"
+)
+
+// webhookStore keeps the created webhooks in memory.
+type webhookStore struct {
+	webhooks []repository.Webhook
+}
+
+func (s *webhookStore) CreateWebhook(ctx context.Context, w repository.Webhook) (repository.Webhook, error) {
+	w.ID = int64(len(s.webhooks) + 1)
+	s.webhooks = append(s.webhooks, w)
+	return w, nil
+}
+
+func (s *webhookStore) Webhooks(ctx context.Context) ([]repository.Webhook, error) {
+	return s.webhooks, nil
+}
+
+func (s *webhookStore) DeleteWebhook(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (s *webhookStore) Deliveries(ctx context.Context, webhookID int64, status string, limit int) ([]repository.Delivery, error) {
+	return nil, nil
+}
+
+func TestWebhooksAuthorization(t *testing.T) {
+	store := &webhookStore{}
+	app := fiber.New(fiber.Config{ErrorHandler: handler.ErrorHandler})
+	handler.NewWebhooks(store, "s3cret").Register(app)
+
+	body := `{"url": "https://example.com/hook"}`
+
+	var res apierror.Error
+	for _, headers := range [][]string{nil, {"Authorization", "Bearer wrong"}, {"Authorization", "s3cret"}} {
+		status := do(t, app, "POST", "/webhooks", body, &res, headers...)
+		if status != 401 || res.Code != apierror.Unauthorized {
+			t.Fatalf("%v: got %d %+v", headers, status, res)
+		}
+	}
+	if len(store.webhooks) != 0 {
+		t.Fatalf("got webhooks %+v", store.webhooks)
+	}
+
+	var created handler.WebhookDto
+	status := do(t, app, "POST", "/webhooks", body, &created, "Authorization", "Bearer s3cret")
+	if status != 201 || created.ID != 1 || created.Secret == "" {
+		t.Fatalf("got %d %+v", status, created)
+	}
+
+	status = do(t, app, "POST", "/webhooks", `{"url": "http://127.0.0.1:8080/hook"}`, &res, "Authorization", "Bearer s3cret")
+	if status != 422 || res.Code != apierror.InvalidPayload || len(store.webhooks) != 1 {
+		t.Fatalf("got %d %+v", status, res)
+	}
+}
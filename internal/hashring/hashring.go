@@ -0,0 +1,73 @@
+// Package hashring maps keys to nodes by consistent hashing, so adding or
+// removing a node only moves the keys that node gains or loses.
+package hashring
+
+import (
+	"cmp"
+	"hash/fnv"
+	"slices"
+	"strconv"
+)
+
+// replicas is how many points each node takes on the ring, which evens out
+// the share of keys each one gets.
+const replicas = 128
+
+type point struct {
+	hash uint64
+	node int
+}
+
+type Ring struct {
+	points []point
+}
+
+// New places nodes on the ring by name. Names, not positions, decide where
+// a node lands, so the same names always give the same mapping whatever
+// their order.
+func New(nodes []string) *Ring {
+	r := &Ring{points: make([]point, 0, len(nodes)*replicas)}
+
+	for i, name := range nodes {
+		for v := 0; v < replicas; v++ {
+			r.points = append(r.points, point{hash: hash(name + "#" + strconv.Itoa(v)), node: i})
+		}
+	}
+
+	slices.SortFunc(r.points, func(a, b point) int {
+		return cmp.Compare(a.hash, b.hash)
+	})
+
+	return r
+}
+
+// Node returns the index, in the slice given to New, of the node owning
+// key: the first one clockwise from the key's hash.
+func (r *Ring) Node(key string) int {
+	h := hash(key)
+
+	i, _ := slices.BinarySearchFunc(r.points, h, func(p point, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.points[i].node
+}
+
+// hash is FNV-1a followed by the splitmix64 finalizer, since FNV alone
+// spreads short keys like "1" and "2" poorly.
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
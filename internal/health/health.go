@@ -0,0 +1,57 @@
+// Package health serves the endpoints probes and load balancers poll.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errNotReady = errors.New("not ready")
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+type statusDto struct {
+	Status string `json:"status"`
+	Error  string `json:"erro,omitempty"`
+}
+
+// Handler responds 200 when every check passes within timeout, or 503 with
+// the first failure.
+func Handler(timeout time.Duration, checks ...Check) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+
+		for _, check := range checks {
+			err := check(ctx)
+			if err != nil {
+				return c.Status(503).JSON(statusDto{Status: "unavailable", Error: err.Error()})
+			}
+		}
+
+		return c.Status(200).JSON(statusDto{Status: "ok"})
+	}
+}
+
+// Gate is a Check failing until the process declares itself ready, e.g.
+// once caches are warm, and again while it shuts down.
+type Gate struct {
+	ready atomic.Bool
+}
+
+func (g *Gate) SetReady(ready bool) {
+	g.ready.Store(ready)
+}
+
+func (g *Gate) Check(ctx context.Context) error {
+	if !g.ready.Load() {
+		return errNotReady
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+// Package httpcache sets HTTP caching headers so a proxy in front of the API
+// can absorb read spikes.
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// New returns a middleware that marks successful responses as cacheable for
+// maxAge. A zero maxAge disables it.
+func New(maxAge time.Duration) fiber.Handler {
+	seconds := int(maxAge / time.Second)
+	cacheControl := fmt.Sprintf("public, max-age=%d", seconds)
+
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if err != nil || maxAge <= 0 || c.Response().StatusCode() != fiber.StatusOK {
+			return err
+		}
+
+		c.Set(fiber.HeaderCacheControl, cacheControl)
+		c.Set(fiber.HeaderExpires, time.Now().Add(maxAge).UTC().Format(http.TimeFormat))
+
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+// Package leader elects one replica, through a Postgres advisory lock, to run
+// the periodic jobs every replica would otherwise repeat.
+package leader
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The session advisory lock held by the leader, in the app-wide class 1
+// shared with migrations, which uses key 0.
+const (
+	lockClass = 1
+	lockKey   = 1
+)
+
+// Pool is a *pgxpool.Pool, or anything standing in front of one.
+type Pool interface {
+	Acquire(ctx context.Context) (*pgxpool.Conn, error)
+}
+
+// Run calls lead while this replica is the leader, until ctx is done. The
+// context given to lead is canceled when leadership is lost, and lead must
+// return then. Replicas that aren't the leader try to become it every
+// interval, and Postgres drops the lock with the leader's connection, so
+// another one takes over within about interval of the leader dying. A
+// leader cut off from the database only notices on its next ping, so jobs
+// must tolerate briefly overlapping with its successor's.
+func Run(ctx context.Context, pool Pool, interval time.Duration, lead func(ctx context.Context)) {
+	for {
+		err := run(ctx, pool, interval, lead)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("Leader election failed, retrying", "in", interval, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// run takes the lock if it is free and, if so, runs lead until the session
+// holding it stops answering or ctx is done.
+func run(ctx context.Context, pool Pool, interval time.Duration, lead func(ctx context.Context)) error {
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	var locked bool
+	err = pooled.QueryRow(ctx, "SELECT pg_try_advisory_lock($1, $2)", lockClass, lockKey).Scan(&locked)
+	if err != nil || !locked {
+		pooled.Release()
+		return err
+	}
+
+	// The lock lives as long as the session, so once held the connection
+	// must not go back to the pool.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	slog.Info("Elected leader")
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+	// Jobs stop before the connection closes and frees the lock.
+	defer func() {
+		cancel()
+		<-done
+		slog.Info("Stepped down as leader")
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-done:
+			return nil
+		case <-ticker.C:
+		}
+
+		pingCtx, cancelPing := context.WithTimeout(ctx, interval)
+		err := conn.Ping(pingCtx)
+		cancelPing()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package leader
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The test needs a database: TEST_DATABASE_URL. It is skipped when unset.
+
+func TestRunFollowerKeepsConnection(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leaderPool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leaderPool.Close()
+
+	elected := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		Run(ctx, leaderPool, time.Second, func(ctx context.Context) {
+			close(elected)
+			<-ctx.Done()
+		})
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	select {
+	case <-elected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not elected")
+	}
+
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		err := run(ctx, pool, time.Second, func(context.Context) {
+			t.Error("elected a second leader")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A follower returns its connection to the pool instead of opening
+	// one per attempt.
+	if n := pool.Stat().NewConnsCount(); n != 1 {
+		t.Fatalf("opened %d connections, want 1", n)
+	}
+}
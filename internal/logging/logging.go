@@ -0,0 +1,148 @@
+// Package logging configures the process-wide slog logger and derives
+// request scoped loggers from it.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/config"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/requestid"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Level is the minimum level of the default logger.
+var Level = new(slog.LevelVar)
+
+// Setup makes a logger at the given level the slog default. format is text
+// or json. Logs go to stdout unless file has a path. The values of the
+// redact attributes are masked.
+func Setup(level, format string, file config.LogFile, redact []string) error {
+	err := Level.UnmarshalText([]byte(level))
+	if err != nil {
+		return err
+	}
+
+	w, opts := output(file), &slog.HandlerOptions{Level: Level, ReplaceAttr: redactor(redact)}
+
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// redacted replaces the value of masked attributes.
+const redacted = "[REDACTED]"
+
+func redactor(keys []string) func(groups []string, a slog.Attr) slog.Attr {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	masked := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		masked[k] = true
+	}
+
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if masked[a.Key] {
+			a.Value = slog.StringValue(redacted)
+		}
+
+		return a
+	}
+}
+
+func output(file config.LogFile) io.Writer {
+	if file.Path == "" {
+		return os.Stdout
+	}
+
+	w := &lumberjack.Logger{
+		Filename:   file.Path,
+		MaxSize:    file.MaxSizeMB,
+		MaxBackups: file.MaxBackups,
+		MaxAge:     file.MaxAgeDays,
+	}
+
+	if file.RotateInterval > 0 {
+		go func() {
+			for range time.Tick(file.RotateInterval) {
+				w.Rotate()
+			}
+		}()
+	}
+
+	return w
+}
+
+type startKey struct{}
+
+// Middleware records when the request started, for the latency field of
+// Request loggers.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(startKey{}, time.Now())
+		return c.Next()
+	}
+}
+
+// Request returns the default logger annotated with the request id, route,
+// client_id when the route has one, and the latency so far.
+func Request(c *fiber.Ctx) *slog.Logger {
+	// Fiber's strings point into buffers reused after the handler returns,
+	// and the logger may outlive it, e.g. in a streamed response.
+	attrs := []any{
+		"request_id", requestid.Get(c),
+		"method", utils.CopyString(c.Method()),
+		"route", c.Route().Path,
+	}
+
+	// Params panics when no route matched, e.g. for a body over the limit.
+	if route := c.Route(); slices.Contains(route.Params, "id") {
+		attrs = append(attrs, "client_id", utils.CopyString(c.Params("id")))
+	}
+
+	if start, ok := c.Locals(startKey{}).(time.Time); ok {
+		attrs = append(attrs, "latency", time.Since(start))
+	}
+
+	return slog.With(attrs...)
+}
+
+// AccessLog returns a middleware logging one line per request with its
+// status, latency and response size.
+func AccessLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+		if err != nil {
+			// Let the ErrorHandler write the response now so the logged
+			// status is the one the client gets.
+			if herr := c.App().ErrorHandler(c, err); herr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		Request(c).Info("Request",
+			"path", c.Path(),
+			"status", c.Response().StatusCode(),
+			"bytes", len(c.Response().Body()),
+		)
+
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+// Package metrics holds the Prometheus collectors of the API and serves them
+// on /metrics.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every collector of the API.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Request latency, by method, route and status.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method", "route", "status"})
+
+	inFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "Requests being handled.",
+	})
+
+	// Panics counts the handler panics recovered so far.
+	Panics = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "http_panics_total",
+		Help: "Handler panics recovered.",
+	})
+)
+
+// Business metrics of the transactions endpoint.
+var (
+	amount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "transactions_amount_total",
+		Help: "Cents moved by accepted transactions, by tipo.",
+	}, []string{"tipo"})
+
+	limitRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "transactions_limit_rejected_total",
+		Help: "Debits rejected for exceeding the client's limit.",
+	})
+
+	clientNotFound = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "transactions_client_not_found_total",
+		Help: "Transactions for unknown clients.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(requests, duration, inFlight, Panics, amount, limitRejections, clientNotFound)
+
+	// GC pauses, heap and goroutine/scheduler stats, to see memory pressure
+	// during load tests.
+	Registry.MustRegister(
+		collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+			collectors.MetricsGC,
+			collectors.MetricsMemory,
+			collectors.MetricsScheduler,
+		)),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Transaction records an accepted transaction of kind c or d.
+func Transaction(kind string, cents int) {
+	amount.WithLabelValues(kind).Add(float64(cents))
+}
+
+// LimitRejected records a debit refused by the limit check.
+func LimitRejected() {
+	limitRejections.Inc()
+}
+
+// ClientNotFound records a transaction for a client that doesn't exist.
+func ClientNotFound() {
+	clientNotFound.Inc()
+}
+
+// Middleware records the request metrics. Errors are handed to the app's
+// ErrorHandler first so the recorded status is the one sent.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		err := c.Next()
+		if err != nil {
+			if herr := c.App().ErrorHandler(c, err); herr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		// Fiber's strings point into reused buffers; labels are kept.
+		labels := prometheus.Labels{
+			"method": utils.CopyString(c.Method()),
+			"route":  c.Route().Path,
+			"status": strconv.Itoa(c.Response().StatusCode()),
+		}
+		requests.With(labels).Inc()
+		duration.With(labels).Observe(time.Since(start).Seconds())
+
+		return nil
+	}
+}
+
+// Handler serves the Registry in the Prometheus text format.
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Unknown ids are whatever clients send, so they must not become series.
+func TestClientNotFoundHasOneSeries(t *testing.T) {
+	before := testutil.ToFloat64(clientNotFound)
+
+	ClientNotFound()
+	ClientNotFound()
+
+	if n := testutil.CollectAndCount(clientNotFound); n != 1 {
+		t.Fatalf("got %d series, want 1", n)
+	}
+	if got := testutil.ToFloat64(clientNotFound) - before; got != 2 {
+		t.Fatalf("counted %v, want 2", got)
+	}
+}
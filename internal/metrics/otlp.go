@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// PushOTLP periodically pushes everything in Registry to an OTLP collector,
+// alongside the /metrics scrape. The exporter and interval follow the
+// standard OTEL_ variables, e.g. OTEL_EXPORTER_OTLP_METRICS_ENDPOINT and
+// OTEL_METRIC_EXPORT_INTERVAL. The returned function flushes and stops it.
+func PushOTLP(ctx context.Context) (func(context.Context) error, error) {
+	exporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "rinha-api")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithProducer(promBridge.NewMetricProducer(promBridge.WithGatherer(Registry))),
+	)
+
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+
+	return provider.Shutdown, nil
+}
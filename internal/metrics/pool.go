@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquired = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_acquired_conns",
+		Help: "Connections currently checked out of the pool.",
+	})
+
+	poolIdle = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_idle_conns",
+		Help: "Idle connections in the pool.",
+	})
+
+	poolTotal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_total_conns",
+		Help: "Connections in the pool, including the ones being constructed.",
+	})
+
+	poolMax = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_max_conns",
+		Help: "Maximum size of the pool.",
+	})
+
+	poolEmptyAcquires = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_empty_acquire_count",
+		Help: "Acquires that had to wait for a connection.",
+	})
+
+	poolAcquireDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_acquire_duration_seconds",
+		Help: "Total time spent waiting to acquire connections.",
+	})
+
+	poolNewConns = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "db_pool_new_conns_count",
+		Help: "Connections constructed since the pool was opened.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(poolAcquired, poolIdle, poolTotal, poolMax, poolEmptyAcquires, poolAcquireDuration, poolNewConns)
+}
+
+// Pool is a *pgxpool.Pool, or anything standing in front of one.
+type Pool interface {
+	Stat() *pgxpool.Stat
+}
+
+// WatchPool samples pool.Stat() every interval until ctx is done.
+func WatchPool(ctx context.Context, pool Pool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stat := pool.Stat()
+			poolAcquired.Set(float64(stat.AcquiredConns()))
+			poolIdle.Set(float64(stat.IdleConns()))
+			poolTotal.Set(float64(stat.TotalConns()))
+			poolMax.Set(float64(stat.MaxConns()))
+			poolEmptyAcquires.Set(float64(stat.EmptyAcquireCount()))
+			poolAcquireDuration.Set(stat.AcquireDuration().Seconds())
+			poolNewConns.Set(float64(stat.NewConnsCount()))
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package migrations applies the versioned SQL files embedded in the binary,
+// recording each applied version in public.schema_migrations.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// The advisory lock taken while migrating, so replicas starting together
+// don't apply the same version twice. Locks are taken in their two-key
+// form: class 1 holds the app-wide locks (this one and the leader's, key
+// 1), class 2 the per-client ones, so neither can collide with a client id.
+const (
+	lockClass = 1
+	lockKey   = 0
+)
+
+type Migration struct {
+	Version string
+	SQL     string
+}
+
+// List returns the embedded migrations sorted by version.
+func List() ([]Migration, error) {
+	names, err := fs.Glob(files, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		content, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, Migration{
+			Version: strings.TrimSuffix(strings.TrimPrefix(name, "sql/"), ".sql"),
+			SQL:     string(content),
+		})
+	}
+
+	return migrations, nil
+}
+
+// Pending returns the versions of the embedded migrations that haven't been
+// applied yet.
+func Pending(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
+	migrations, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pool.Query(ctx, "SELECT version FROM public.schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(done))
+	for _, v := range done {
+		seen[v] = true
+	}
+
+	var pending []string
+	for _, m := range migrations {
+		if !seen[m.Version] {
+			pending = append(pending, m.Version)
+		}
+	}
+
+	return pending, nil
+}
+
+// Apply runs every migration that hasn't been applied yet and returns the
+// versions it applied.
+func Apply(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
+	migrations, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []string
+
+	err = pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, $2)", lockClass, lockKey)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			CREATE TABLE IF NOT EXISTS public.schema_migrations (
+				version text NOT NULL,
+				applied_at timestamptz NOT NULL DEFAULT now(),
+				CONSTRAINT schema_migrations_pk PRIMARY KEY (version)
+			)
+		`)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, "SELECT version FROM public.schema_migrations")
+		if err != nil {
+			return err
+		}
+
+		done, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool, len(done))
+		for _, v := range done {
+			seen[v] = true
+		}
+
+		for _, m := range migrations {
+			if seen[m.Version] {
+				continue
+			}
+
+			_, err = tx.Exec(ctx, m.SQL)
+			if err != nil {
+				return fmt.Errorf("Migration %s failed: %w", m.Version, err)
+			}
+
+			_, err = tx.Exec(ctx, "INSERT INTO public.schema_migrations (version) VALUES ($1)", m.Version)
+			if err != nil {
+				return err
+			}
+
+			applied = append(applied, m.Version)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
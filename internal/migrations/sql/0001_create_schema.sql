@@ -0,0 +1,20 @@
+CREATE SCHEMA IF NOT EXISTS bank;
+
+CREATE TABLE IF NOT EXISTS bank.clients (
+	id int NOT NULL,
+	"limit" int NOT NULL,
+	balance int NOT NULL DEFAULT 0,
+	CONSTRAINT clients_pk PRIMARY KEY (id),
+	CONSTRAINT clients_balance_check CHECK (balance >= -"limit")
+);
+
+CREATE TABLE IF NOT EXISTS bank.transactions (
+	id bigserial NOT NULL,
+	client_id int4 NOT NULL,
+	amount int4 NOT NULL DEFAULT 0,
+	description varchar(10) NULL,
+	"type" char NULL,
+	created_at timestamp NULL,
+	CONSTRAINT transactions_pk PRIMARY KEY (id),
+	CONSTRAINT transactions_clients_fk FOREIGN KEY (client_id) REFERENCES bank.clients(id)
+);
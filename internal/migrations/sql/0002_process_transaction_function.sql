@@ -0,0 +1,41 @@
+-- Locks the client row, updates the balance and records the transaction.
+-- The limit itself is enforced by the clients_balance_check constraint, so a
+-- debit past it fails with 23514. Returns no rows when the client doesn't
+-- exist.
+CREATE OR REPLACE FUNCTION bank.process_transaction(
+  p_client_id int,
+  p_amount int,
+  p_type char,
+  p_description varchar(10)
+)
+RETURNS TABLE (new_balance int, client_limit int, status text)
+LANGUAGE plpgsql
+AS $$
+DECLARE
+  v_balance int;
+  v_limit int;
+BEGIN
+  SELECT c.balance, c."limit"
+  INTO v_balance, v_limit
+  FROM bank.clients c
+  WHERE c.id = p_client_id
+  FOR UPDATE;
+
+  IF NOT FOUND THEN
+    RETURN;
+  END IF;
+
+  IF p_type = 'd' THEN
+    v_balance := v_balance - p_amount;
+  ELSE
+    v_balance := v_balance + p_amount;
+  END IF;
+
+  UPDATE bank.clients SET balance = v_balance WHERE id = p_client_id;
+
+  INSERT INTO bank.transactions (client_id, amount, description, "type", created_at)
+  VALUES (p_client_id, p_amount, p_description, p_type, now());
+
+  RETURN QUERY SELECT v_balance, v_limit, 'ok'::text;
+END;
+$$;
@@ -0,0 +1,5 @@
+-- Links the two ledger rows written by a transfer to each other.
+ALTER TABLE bank.transactions ADD COLUMN IF NOT EXISTS linked_id bigint NULL;
+
+ALTER TABLE bank.transactions ADD CONSTRAINT transactions_linked_fk
+	FOREIGN KEY (linked_id) REFERENCES bank.transactions(id) ON DELETE SET NULL;
@@ -0,0 +1,8 @@
+-- Points a reversal (estorno) at the transaction it compensates. The unique
+-- constraint guarantees a transaction is reversed at most once.
+ALTER TABLE bank.transactions ADD COLUMN IF NOT EXISTS reversal_of bigint NULL;
+
+ALTER TABLE bank.transactions ADD CONSTRAINT transactions_reversal_of_fk
+	FOREIGN KEY (reversal_of) REFERENCES bank.transactions(id) ON DELETE SET NULL;
+
+ALTER TABLE bank.transactions ADD CONSTRAINT transactions_reversal_of_uq UNIQUE (reversal_of);
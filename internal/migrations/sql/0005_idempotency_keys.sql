@@ -0,0 +1,11 @@
+-- Remembers the response of each transaction created with an
+-- Idempotency-Key, so retries don't apply the same debit twice.
+CREATE TABLE IF NOT EXISTS bank.idempotency_keys (
+	client_id int NOT NULL,
+	"key" varchar(255) NOT NULL,
+	balance int NULL,
+	"limit" int NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT idempotency_keys_pk PRIMARY KEY (client_id, "key"),
+	CONSTRAINT idempotency_keys_clients_fk FOREIGN KEY (client_id) REFERENCES bank.clients(id) ON DELETE CASCADE
+);
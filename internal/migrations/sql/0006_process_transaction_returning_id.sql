@@ -0,0 +1,52 @@
+-- process_transaction now also returns the id and timestamp of the row it
+-- inserted. The return type changes, so the function is recreated.
+DROP FUNCTION IF EXISTS bank.process_transaction(int, int, char, varchar);
+
+CREATE FUNCTION bank.process_transaction(
+  p_client_id int,
+  p_amount int,
+  p_type char,
+  p_description varchar(10)
+)
+RETURNS TABLE (
+  new_balance int,
+  client_limit int,
+  status text,
+  transaction_id bigint,
+  transaction_created_at timestamp
+)
+LANGUAGE plpgsql
+AS $$
+DECLARE
+  v_balance int;
+  v_limit int;
+  v_id bigint;
+  v_created_at timestamp;
+BEGIN
+  SELECT c.balance, c."limit"
+  INTO v_balance, v_limit
+  FROM bank.clients c
+  WHERE c.id = p_client_id
+  FOR UPDATE;
+
+  IF NOT FOUND THEN
+    RETURN;
+  END IF;
+
+  IF p_type = 'd' THEN
+    v_balance := v_balance - p_amount;
+  ELSE
+    v_balance := v_balance + p_amount;
+  END IF;
+
+  UPDATE bank.clients SET balance = v_balance WHERE id = p_client_id;
+
+  INSERT INTO bank.transactions (client_id, amount, description, "type", created_at)
+  VALUES (p_client_id, p_amount, p_description, p_type, now())
+  RETURNING id, created_at INTO v_id, v_created_at;
+
+  RETURN QUERY SELECT v_balance, v_limit, 'ok'::text, v_id, v_created_at;
+END;
+$$;
+
+ALTER TABLE bank.idempotency_keys ADD COLUMN IF NOT EXISTS transaction_id bigint NULL;
@@ -0,0 +1,3 @@
+-- Serves the per-client ledger lookups (statements, latest id for ETags)
+-- without scanning the whole table.
+CREATE INDEX IF NOT EXISTS transactions_client_id_idx ON bank.transactions (client_id, id DESC);
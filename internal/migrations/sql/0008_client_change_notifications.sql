@@ -0,0 +1,20 @@
+-- Announces every change to a client on the bank_clients channel, so each
+-- replica can keep its balance cache current. The payload is the id, then
+-- the balance and limit unless the client was deleted.
+CREATE OR REPLACE FUNCTION bank.notify_client_change() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM pg_notify('bank_clients', OLD.id::text);
+	ELSE
+		PERFORM pg_notify('bank_clients', format('%s,%s,%s', NEW.id, NEW.balance, NEW."limit"));
+	END IF;
+
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clients_notify_change ON bank.clients;
+
+CREATE TRIGGER clients_notify_change
+	AFTER INSERT OR UPDATE OR DELETE ON bank.clients
+	FOR EACH ROW EXECUTE FUNCTION bank.notify_client_change();
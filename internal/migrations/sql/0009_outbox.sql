@@ -0,0 +1,11 @@
+-- Holds the domain events written in the same transaction as the ledger
+-- rows they describe, until the relay has published them. Rows are deleted
+-- once published.
+CREATE TABLE IF NOT EXISTS bank.outbox (
+	id bigserial NOT NULL,
+	client_id int NOT NULL,
+	"type" varchar(64) NOT NULL,
+	payload jsonb NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT outbox_pk PRIMARY KEY (id)
+);
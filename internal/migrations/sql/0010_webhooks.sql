@@ -0,0 +1,32 @@
+-- Webhooks receive a signed POST for every event of their client, or of
+-- every client when client_id is null.
+CREATE TABLE IF NOT EXISTS bank.webhooks (
+	id bigserial NOT NULL,
+	client_id int NULL,
+	url varchar(2048) NOT NULL,
+	secret varchar(64) NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT webhooks_pk PRIMARY KEY (id),
+	CONSTRAINT webhooks_clients_fk FOREIGN KEY (client_id) REFERENCES bank.clients(id) ON DELETE CASCADE
+);
+
+-- One row per event and webhook, written with the event. Pending rows are
+-- retried at next_attempt_at until delivered or out of attempts.
+CREATE TABLE IF NOT EXISTS bank.webhook_deliveries (
+	id bigserial NOT NULL,
+	webhook_id bigint NOT NULL,
+	"type" varchar(64) NOT NULL,
+	payload jsonb NOT NULL,
+	status varchar(16) NOT NULL DEFAULT 'pending',
+	attempts int NOT NULL DEFAULT 0,
+	next_attempt_at timestamptz NOT NULL DEFAULT now(),
+	last_status int NULL,
+	last_error text NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	delivered_at timestamptz NULL,
+	CONSTRAINT webhook_deliveries_pk PRIMARY KEY (id),
+	CONSTRAINT webhook_deliveries_webhooks_fk FOREIGN KEY (webhook_id) REFERENCES bank.webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON bank.webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON bank.webhook_deliveries (webhook_id, id DESC);
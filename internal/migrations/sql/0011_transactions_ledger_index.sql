@@ -0,0 +1,6 @@
+-- Replaces transactions_client_id_idx with one also holding amount and type,
+-- so statements sum the transactions newer than their page from the index
+-- alone.
+CREATE INDEX IF NOT EXISTS transactions_client_ledger_idx ON bank.transactions (client_id, id DESC) INCLUDE (amount, "type");
+
+DROP INDEX IF EXISTS bank.transactions_client_id_idx;
@@ -0,0 +1,15 @@
+-- Databases created by the former initdb scripts already had bank.clients
+-- when 0001 ran, so its CREATE TABLE IF NOT EXISTS never added the limit
+-- check there. NOT VALID skips checking existing rows, which may already be
+-- past their limit, while every write from now on is.
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1
+    FROM pg_constraint
+    WHERE conrelid = 'bank.clients'::regclass AND conname = 'clients_balance_check'
+  ) THEN
+    ALTER TABLE bank.clients ADD CONSTRAINT clients_balance_check CHECK (balance >= -"limit") NOT VALID;
+  END IF;
+END;
+$$;
@@ -0,0 +1,11 @@
+-- NOTIFY takes a global lock at commit, which serialized every write to
+-- bank.clients whether or not anyone was listening. Only connections that
+-- set bank.notify_client_changes, which replicas caching clients in memory
+-- do, announce their changes now.
+DROP TRIGGER IF EXISTS clients_notify_change ON bank.clients;
+
+CREATE TRIGGER clients_notify_change
+	AFTER INSERT OR UPDATE OR DELETE ON bank.clients
+	FOR EACH ROW
+	WHEN (current_setting('bank.notify_client_changes', true) = 'on')
+	EXECUTE FUNCTION bank.notify_client_change();
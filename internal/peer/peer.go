@@ -0,0 +1,87 @@
+// Package peer sends each client's requests to the replica owning it, so a
+// client is only ever served by one process, which can then keep it in
+// memory without coordinating with the others.
+package peer
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/hashring"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/logging"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"github.com/valyala/fasthttp"
+)
+
+// ErrUnavailable means the owning peer didn't answer a forwarded request.
+var ErrUnavailable = errors.New("owning peer unavailable")
+
+// forwardedHeader marks a forwarded request, carrying the secret shared by
+// the peers. The receiver serves it whoever it thinks the owner is, so
+// peers whose lists disagree can't bounce a request between them.
+const forwardedHeader = "X-Forwarded-By-Peer"
+
+// New returns middleware for routes with an :id param that forwards the
+// request to the peer owning the client and relays its response, as it
+// arrives when the request Accepts text/event-stream. Upgrades, like
+// WebSocket, are tunneled to the owner. peers are base URLs like
+// http://api01:9999, and self must be one of them. Only requests carrying
+// secret, which every peer must share, are taken as forwarded, so clients
+// can't pick the replica that serves them.
+func New(self string, peers []string, secret string) fiber.Handler {
+	ring := hashring.New(peers)
+	client := &fasthttp.Client{NoDefaultUserAgentHeader: true}
+	streams := &fasthttp.Client{NoDefaultUserAgentHeader: true, StreamResponseBody: true}
+
+	return func(c *fiber.Ctx) error {
+		if Forwarded(c, secret) {
+			return c.Next()
+		}
+
+		owner := peers[ring.Node(c.Params("id"))]
+		if owner == self {
+			return c.Next()
+		}
+
+		c.Request().Header.Set(forwardedHeader, secret)
+
+		var err error
+		switch deadline, ok := c.UserContext().Deadline(); {
+		case isUpgrade(c):
+			err = tunnel(c, owner)
+		case c.Get(fiber.HeaderAccept) == eventStream:
+			err = stream(c, owner, streams)
+		case ok:
+			err = proxy.DoDeadline(c, owner+c.OriginalURL(), deadline, client)
+		default:
+			err = proxy.Do(c, owner+c.OriginalURL(), client)
+		}
+		if err != nil {
+			logging.Request(c).Warn("Unable to forward request", "peer", owner, "error", err)
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+
+		return nil
+	}
+}
+
+// Forwarded reports whether the request was forwarded by a peer sharing
+// secret.
+func Forwarded(c *fiber.Ctx, secret string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(c.Get(forwardedHeader)), []byte(secret)) == 1
+}
+
+// Exempt returns h wrapped to pass requests forwarded by a peer sharing
+// secret straight on, for middleware like the IP rate limiter that the
+// replica first taking the request has already applied.
+func Exempt(secret string, h fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if Forwarded(c, secret) {
+			return c.Next()
+		}
+
+		return h(c)
+	}
+}
@@ -0,0 +1,139 @@
+package peer
+
+import (
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/hashring"
+	"github.com/gofiber/fiber/v2"
+)
+
+const testSecret = "s3cret"
+
+// newReplica serves /clientes/:id behind the peer middleware, answering
+// with name, and returns its base URL.
+func newReplica(t *testing.T, name string, ln net.Listener, peers []string) *fiber.App {
+	t.Helper()
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use("/clientes/:id", New("http://"+ln.Addr().String(), peers, testSecret))
+	app.Get("/clientes/:id", func(c *fiber.Ctx) error {
+		return c.SendString(name)
+	})
+
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+
+	return app
+}
+
+// replicas starts two replicas peering with each other and returns them
+// along with the id of a client the second one owns.
+func replicas(t *testing.T) (*fiber.App, *fiber.App, int) {
+	t.Helper()
+
+	var lns [2]net.Listener
+	var peers []string
+	for i := range lns {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		lns[i] = ln
+		peers = append(peers, "http://"+ln.Addr().String())
+	}
+
+	ring := hashring.New(peers)
+	id := 1
+	for ring.Node(strconv.Itoa(id)) != 1 {
+		id++
+	}
+
+	return newReplica(t, "a", lns[0], peers), newReplica(t, "b", lns[1], peers), id
+}
+
+func get(t *testing.T, app *fiber.App, target string, headers ...string) (int, string) {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", target, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	res, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.StatusCode, string(body)
+}
+
+func TestForwardsToOwner(t *testing.T) {
+	a, _, id := replicas(t)
+
+	status, body := get(t, a, "/clientes/"+strconv.Itoa(id))
+	if status != 200 || body != "b" {
+		t.Fatalf("got %d %q, want the owner's answer", status, body)
+	}
+}
+
+func TestForwardedHeaderNeedsSecret(t *testing.T) {
+	a, _, id := replicas(t)
+	target := "/clientes/" + strconv.Itoa(id)
+
+	// A client can't make a non-owner serve it.
+	status, body := get(t, a, target, forwardedHeader, "http://evil")
+	if status != 200 || body != "b" {
+		t.Fatalf("got %d %q, want the owner's answer", status, body)
+	}
+
+	status, body = get(t, a, target, forwardedHeader, testSecret)
+	if status != 200 || body != "a" {
+		t.Fatalf("got %d %q, want a forwarded request served where it lands", status, body)
+	}
+}
+
+func TestExempt(t *testing.T) {
+	app := fiber.New()
+	app.Use(Exempt(testSecret, func(c *fiber.Ctx) error {
+		return fiber.ErrTooManyRequests
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(204)
+	})
+
+	for _, tc := range []struct {
+		header string
+		want   int
+	}{
+		{"", 429},
+		{"wrong", 429},
+		{testSecret, 204},
+	} {
+		status, _ := get(t, app, "/", forwardedHeader, tc.header)
+		if status != tc.want {
+			t.Fatalf("header %q got %d, want %d", tc.header, status, tc.want)
+		}
+	}
+}
+
+func TestForwardedWithoutSecret(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(strconv.FormatBool(Forwarded(c, "")))
+	})
+
+	_, body := get(t, app, "/", forwardedHeader, "")
+	if body != "false" {
+		t.Fatal("empty secret matched an empty header")
+	}
+}
@@ -0,0 +1,73 @@
+package peer
+
+import (
+	"bufio"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// eventStream is the Accept of a request for Server-Sent Events, whose
+// response never ends and so can't be relayed whole.
+const eventStream = "text/event-stream"
+
+// stream forwards the request to owner and relays the response body as it
+// arrives, for as long as the owner keeps sending it.
+func stream(c *fiber.Ctx, owner string, client *fasthttp.Client) error {
+	req := fasthttp.AcquireRequest()
+	c.Request().CopyTo(req)
+	req.SetRequestURI(owner + c.OriginalURL())
+	req.Header.Del(fiber.HeaderConnection)
+
+	resp := fasthttp.AcquireResponse()
+	err := client.Do(req, resp)
+	fasthttp.ReleaseRequest(req)
+	if err != nil {
+		fasthttp.ReleaseResponse(resp)
+		return err
+	}
+
+	resp.Header.CopyTo(&c.Response().Header)
+	c.Response().Header.Del(fiber.HeaderConnection)
+
+	body := resp.BodyStream()
+	if body == nil {
+		c.Response().SetBodyRaw(append([]byte(nil), resp.Body()...))
+		fasthttp.ReleaseResponse(resp)
+		return nil
+	}
+
+	writeTimeout := c.App().Config().WriteTimeout
+	conn := c.Context().Conn()
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// Closing the body drops the connection to the owner, which ends
+		// its side of the stream.
+		defer fasthttp.ReleaseResponse(resp)
+		defer resp.CloseBodyStream()
+
+		buf := make([]byte, 4096)
+		for {
+			n, err := body.Read(buf)
+			if n > 0 {
+				// The server only sets a write deadline once per
+				// response, which a long stream would outlive.
+				if writeTimeout > 0 {
+					conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+				}
+
+				_, werr := w.Write(buf[:n])
+				if werr != nil || w.Flush() != nil {
+					return
+				}
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package peer
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// dialTimeout bounds connecting to the owner to tunnel a request.
+const dialTimeout = 5 * time.Second
+
+// isUpgrade reports whether the request asks to switch to a protocol, like
+// WebSocket, that takes the connection over.
+func isUpgrade(c *fiber.Ctx) bool {
+	return c.Get(fiber.HeaderUpgrade) != "" &&
+		strings.Contains(strings.ToLower(c.Get(fiber.HeaderConnection)), "upgrade")
+}
+
+// tunnel sends the request to owner on a connection of its own and, once
+// the handler returns, joins the client's connection to it, so the owner
+// answers the upgrade and the two then talk directly.
+func tunnel(c *fiber.Ctx, owner string) error {
+	u, err := url.Parse(owner)
+	if err != nil {
+		return err
+	}
+
+	upstream, err := net.DialTimeout("tcp", u.Host, dialTimeout)
+	if err != nil {
+		return err
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	c.Request().CopyTo(req)
+
+	w := bufio.NewWriter(upstream)
+	err = req.Write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		upstream.Close()
+		return err
+	}
+
+	c.Context().HijackSetNoResponse(true)
+	c.Context().Hijack(func(conn net.Conn) {
+		defer upstream.Close()
+
+		go func() {
+			io.Copy(upstream, conn)
+			// Ends the copy below once the client is gone.
+			upstream.Close()
+		}()
+
+		io.Copy(conn, upstream)
+	})
+
+	return nil
+}
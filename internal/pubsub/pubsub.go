@@ -0,0 +1,115 @@
+// Package pubsub fans updates about a client out to whoever is watching
+// it, within one process. Publishers never wait: a subscriber that falls
+// behind is cut off instead, and is expected to subscribe again and catch
+// up from storage.
+package pubsub
+
+import "sync"
+
+// Hub holds the subscriptions, by client id.
+type Hub[T any] struct {
+	buffer int
+
+	mu     sync.Mutex
+	subs   map[int]map[*Subscription[T]]struct{}
+	closed bool
+}
+
+// New returns a hub whose subscribers may be up to buffer updates behind
+// before they are cut off.
+func New[T any](buffer int) *Hub[T] {
+	return &Hub[T]{buffer: buffer, subs: make(map[int]map[*Subscription[T]]struct{})}
+}
+
+// Subscription receives the updates published for one client.
+type Subscription[T any] struct {
+	// C is closed when the subscription ends: when it is closed, when it
+	// fell behind, or when the hub closed.
+	C <-chan T
+
+	c   chan T
+	hub *Hub[T]
+	id  int
+}
+
+// Subscribe starts receiving the updates published for id from now on.
+// Once the hub is closed the subscription starts closed.
+func (h *Hub[T]) Subscribe(id int) *Subscription[T] {
+	c := make(chan T, h.buffer)
+	s := &Subscription[T]{C: c, c: c, hub: h, id: id}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(c)
+		return s
+	}
+
+	if h.subs[id] == nil {
+		h.subs[id] = make(map[*Subscription[T]]struct{})
+	}
+	h.subs[id][s] = struct{}{}
+
+	return s
+}
+
+// Publish hands v to every subscriber of id without blocking, ending the
+// subscriptions whose buffer is full.
+func (h *Hub[T]) Publish(id int, v T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs[id] {
+		select {
+		case s.c <- v:
+		default:
+			h.remove(s)
+		}
+	}
+}
+
+// Watched reports whether id has subscribers, so publishers can skip
+// building updates nobody receives.
+func (h *Hub[T]) Watched(id int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subs[id]) > 0
+}
+
+// Close ends every subscription, and those made from now on, so streams
+// don't hold up a shutdown.
+func (h *Hub[T]) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subs {
+		for s := range subs {
+			h.remove(s)
+		}
+	}
+}
+
+// remove must be called with mu held.
+func (h *Hub[T]) remove(s *Subscription[T]) {
+	subs, ok := h.subs[s.id]
+	if _, subscribed := subs[s]; !ok || !subscribed {
+		return
+	}
+
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(h.subs, s.id)
+	}
+	close(s.c)
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription[T]) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
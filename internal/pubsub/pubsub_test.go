@@ -0,0 +1,61 @@
+package pubsub
+
+import "testing"
+
+func TestPublish(t *testing.T) {
+	hub := New[int](2)
+
+	sub := hub.Subscribe(1)
+	other := hub.Subscribe(2)
+	if !hub.Watched(1) || hub.Watched(3) {
+		t.Fatal("Watched doesn't follow the subscriptions")
+	}
+
+	hub.Publish(1, 10)
+	if v := <-sub.C; v != 10 {
+		t.Fatalf("got %d, want 10", v)
+	}
+	if len(other.C) != 0 {
+		t.Fatal("update reached another client's subscriber")
+	}
+
+	sub.Close()
+	sub.Close()
+	if hub.Watched(1) {
+		t.Fatal("closed subscription still watched")
+	}
+}
+
+func TestPublishCutsOffSlowSubscriber(t *testing.T) {
+	hub := New[int](2)
+	sub := hub.Subscribe(1)
+
+	for i := 0; i < 3; i++ {
+		hub.Publish(1, i)
+	}
+
+	got := 0
+	for range sub.C {
+		got++
+	}
+	if got != 2 || hub.Watched(1) {
+		t.Fatalf("got %d updates before the cut off, want 2", got)
+	}
+}
+
+func TestClose(t *testing.T) {
+	hub := New[int](1)
+	sub := hub.Subscribe(1)
+
+	hub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Fatal("subscription open after Close")
+	}
+
+	if _, ok := <-hub.Subscribe(1).C; ok {
+		t.Fatal("subscription made after Close is open")
+	}
+
+	// Publishing to a closed hub is a no-op.
+	hub.Publish(1, 1)
+}
@@ -0,0 +1,132 @@
+// Package ratelimit throttles requests with token buckets, so a single hot
+// key cannot starve the others of database connections.
+package ratelimit
+
+import (
+	"container/list"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrLimited is returned once a key has used up its bucket.
+var ErrLimited = errors.New("rate limit exceeded")
+
+// maxKeys bounds the buckets kept; past it, the least recently used one is
+// dropped, which has most likely refilled and so behaves as a new one.
+const maxKeys = 10000
+
+type bucket[K comparable] struct {
+	key    K
+	tokens float64
+	last   time.Time
+}
+
+// Limiter holds one token bucket per key. Each bucket refills at rate
+// tokens per second up to burst.
+type Limiter[K comparable] struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[K]*list.Element
+	// recent orders the buckets from the most to the least recently used.
+	recent *list.List
+}
+
+func NewLimiter[K comparable](rate, burst int) *Limiter[K] {
+	return &Limiter[K]{
+		rate:    float64(rate),
+		burst:   float64(burst),
+		buckets: make(map[K]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+// Allow takes a token from key's bucket. When it is empty, it returns false
+// and how long until the next token.
+func (l *Limiter[K]) Allow(key K) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.buckets[key]
+	if ok {
+		l.recent.MoveToFront(e)
+	} else {
+		if l.recent.Len() >= maxKeys {
+			oldest := l.recent.Remove(l.recent.Back()).(*bucket[K])
+			delete(l.buckets, oldest.key)
+		}
+
+		e = l.recent.PushFront(&bucket[K]{key: key, tokens: l.burst, last: now})
+		l.buckets[key] = e
+	}
+
+	b := e.Value.(*bucket[K])
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+
+	b.tokens--
+
+	return true, 0
+}
+
+// New returns a middleware limiting requests per key. Requests key can't
+// tell apart, reporting false, pass through. Rejected requests get a
+// Retry-After header and fail with ErrLimited. A zero rate disables it.
+func New[K comparable](rate, burst int, key func(*fiber.Ctx) (K, bool)) fiber.Handler {
+	if rate <= 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	l := NewLimiter[K](rate, burst)
+
+	return func(c *fiber.Ctx) error {
+		k, ok := key(c)
+		if !ok {
+			return c.Next()
+		}
+
+		ok, wait := l.Allow(k)
+		if !ok {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return ErrLimited
+		}
+
+		return c.Next()
+	}
+}
+
+// ByIP keys requests by source address. Behind a trusted proxy that is the
+// last X-Forwarded-For entry, the one the proxy appended itself, since the
+// earlier ones are whatever the client sent.
+func ByIP(c *fiber.Ctx) (string, bool) {
+	if c.IsProxyTrusted() {
+		if ips := c.IPs(); len(ips) > 0 {
+			// Copied, as it points into the request buffer.
+			return strings.Clone(ips[len(ips)-1]), true
+		}
+	}
+
+	return c.Context().RemoteIP().String(), true
+}
+
+// ByClient keys requests by their :id route parameter, as the client id it
+// parses to. Invalid ids are left for the handlers to reject.
+func ByClient(c *fiber.Ctx) (int, bool) {
+	id, err := strconv.Atoi(c.Params("id"))
+	return id, err == nil && id > 0
+}
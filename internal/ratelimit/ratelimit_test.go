@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	l := NewLimiter[int](1, 2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(1); !ok {
+			t.Fatalf("request %d refused within the burst", i)
+		}
+	}
+
+	ok, wait := l.Allow(1)
+	if ok || wait <= 0 {
+		t.Fatalf("got %v, %v past the burst", ok, wait)
+	}
+
+	if ok, _ := l.Allow(2); !ok {
+		t.Fatal("another key refused")
+	}
+}
+
+func TestLimiterEvictsLeastRecentlyUsed(t *testing.T) {
+	l := NewLimiter[int](1, 1)
+
+	for key := 0; key < maxKeys; key++ {
+		l.Allow(key)
+	}
+	// Key 0 is used again, so key 1 is the one evicted next.
+	l.Allow(0)
+	l.Allow(maxKeys)
+
+	if len(l.buckets) != maxKeys || l.recent.Len() != maxKeys {
+		t.Fatalf("got %d buckets, want %d", len(l.buckets), maxKeys)
+	}
+
+	if _, ok := l.buckets[1]; ok {
+		t.Fatal("key 1 not evicted")
+	}
+
+	if ok, _ := l.Allow(1); !ok {
+		t.Fatal("evicted key refused")
+	}
+}
+
+func TestByClient(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+		if errors.Is(err, ErrLimited) {
+			return c.SendStatus(429)
+		}
+		return c.SendStatus(500)
+	}})
+	app.Use("/clientes/:id", New(1, 1, ByClient))
+	app.Get("/clientes/:id", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	get := func(target string) *http.Response {
+		res, err := app.Test(httptest.NewRequest("GET", target, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	if res := get("/clientes/1"); res.StatusCode != 200 {
+		t.Fatalf("got %d", res.StatusCode)
+	}
+
+	// Spellings of the same id share its bucket.
+	res := get("/clientes/01")
+	if res.StatusCode != 429 || res.Header.Get(fiber.HeaderRetryAfter) != "1" {
+		t.Fatalf("got %d with Retry-After %q", res.StatusCode, res.Header.Get(fiber.HeaderRetryAfter))
+	}
+
+	// Invalid ids aren't counted.
+	for i := 0; i < 2; i++ {
+		if res := get("/clientes/x"); res.StatusCode != 200 {
+			t.Fatalf("got %d", res.StatusCode)
+		}
+	}
+}
+
+func TestByIP(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		trusted []string
+		want    string
+	}{
+		{"behind a trusted proxy", []string{"0.0.0.0"}, "10.0.0.2"},
+		{"from anyone else", []string{"10.0.0.9"}, "0.0.0.0"},
+	} {
+		app := fiber.New(fiber.Config{EnableTrustedProxyCheck: true, TrustedProxies: tc.trusted})
+
+		var got string
+		app.Get("/", func(c *fiber.Ctx) error {
+			got, _ = ByIP(c)
+			return nil
+		})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(fiber.HeaderXForwardedFor, "10.0.0.1, 10.0.0.2")
+		_, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != tc.want {
+			t.Fatalf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
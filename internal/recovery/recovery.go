@@ -0,0 +1,25 @@
+// Package recovery turns handler panics into 500 responses instead of
+// dropping the connection.
+package recovery
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/logging"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+// New returns a middleware that recovers panics, logs them with their stack
+// trace and hands them to the app's ErrorHandler as errors.
+func New() fiber.Handler {
+	return recover.New(recover.Config{
+		EnableStackTrace: true,
+		StackTraceHandler: func(c *fiber.Ctx, e any) {
+			metrics.Panics.Inc()
+			logging.Request(c).Error("Recovered panic", "panic", fmt.Sprint(e), "stack", string(debug.Stack()))
+		},
+	})
+}
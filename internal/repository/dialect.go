@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// dialect is what changes between the databases SQL runs on.
+type dialect struct {
+	// open connects to the database at databaseURL.
+	open func(databaseURL string) (*sql.DB, error)
+	// quote wraps identifiers that are reserved words, like "limit".
+	quote string
+	// forUpdate locks the rows a SELECT reads until the transaction ends.
+	// It is empty where writers already exclude each other.
+	forUpdate string
+	// schema creates the tables when they don't exist yet.
+	schema []string
+	// tableExists counts the tables named by its only argument.
+	tableExists string
+
+	isUniqueViolation func(error) bool
+	// isRetryable reports deadlocks and other failures worth running the
+	// transaction again for.
+	isRetryable func(error) bool
+	// isTimeout reports the database giving up waiting on a lock.
+	isTimeout func(error) bool
+}
+
+// sqlQueries are the statements SQL runs, built once for its dialect.
+type sqlQueries struct {
+	clientIDs         string
+	clients           string
+	findClient        string
+	lockClient        string
+	lockClients       string
+	createClient      string
+	updateLimit       string
+	updateBalance     string
+	deleteClient      string
+	deleteKeys        string
+	deleteLedger      string
+	findKey           string
+	insertKey         string
+	insertTransaction string
+	linkTransaction   string
+	findReversible    string
+	findTransaction   string
+	eachTransaction   string
+	lastTransactionID string
+	ledger            string
+}
+
+// build writes every query for d. They are written here with "double
+// quoted" identifiers and ? placeholders.
+func (d dialect) build() sqlQueries {
+	q := func(query string) string {
+		return strings.ReplaceAll(query, `"`, d.quote)
+	}
+
+	return sqlQueries{
+		clientIDs:     q(`SELECT id FROM clients`),
+		clients:       q(`SELECT id, "limit", balance FROM clients`),
+		findClient:    q(`SELECT "limit", balance FROM clients WHERE id = ?`),
+		lockClient:    q(`SELECT "limit", balance FROM clients WHERE id = ?` + d.forUpdate),
+		lockClients:   q(`SELECT id, "limit", balance FROM clients WHERE id IN (?, ?) ORDER BY id` + d.forUpdate),
+		createClient:  q(`INSERT INTO clients (id, "limit", balance) VALUES (?, ?, ?)`),
+		updateLimit:   q(`UPDATE clients SET "limit" = ? WHERE id = ?`),
+		updateBalance: q(`UPDATE clients SET balance = ? WHERE id = ?`),
+		deleteClient:  q(`DELETE FROM clients WHERE id = ?`),
+		deleteKeys:    q(`DELETE FROM idempotency_keys WHERE client_id = ?`),
+		deleteLedger:  q(`DELETE FROM transactions WHERE client_id = ?`),
+		findKey: q(`
+			SELECT k.balance, k."limit", k.transaction_id, t.created_at
+			FROM idempotency_keys k
+			JOIN transactions t ON t.id = k.transaction_id
+			WHERE k.client_id = ? AND k."key" = ?
+		`),
+		insertKey: q(`
+			INSERT INTO idempotency_keys (client_id, "key", balance, "limit", transaction_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`),
+		insertTransaction: q(`
+			INSERT INTO transactions (client_id, amount, description, "type", created_at, linked_id, reversal_of)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`),
+		linkTransaction:   q(`UPDATE transactions SET linked_id = ? WHERE id = ?`),
+		findReversible:    q(`SELECT amount, "type", reversal_of FROM transactions WHERE id = ? AND client_id = ?`),
+		findTransaction:   q(`SELECT amount, description, "type", created_at FROM transactions WHERE id = ? AND client_id = ?`),
+		eachTransaction:   q(`SELECT id, amount, description, "type", created_at FROM transactions WHERE client_id = ? ORDER BY id`),
+		lastTransactionID: q(`SELECT COALESCE(MAX(id), 0) FROM transactions WHERE client_id = ?`),
+		ledger:            q(`SELECT id, amount, description, "type", created_at FROM transactions WHERE client_id = ? ORDER BY id DESC`),
+	}
+}
+
+// dialects are the databases SQL supports, by STORAGE name.
+var dialects = map[string]dialect{
+	"mysql":  mysqlDialect,
+	"sqlite": sqliteDialect,
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var errNoDatabase = errors.New("No database URL given")
+
+// failoverAfter is how many consecutive failed pings take the primary out.
+const failoverAfter = 3
+
+// Failover keeps a pool open to one of several databases: the first
+// reachable one in order, then the next that answers once it dies. Callers
+// go through Pool on every use so a promotion reaches them transparently.
+type Failover struct {
+	urls    []string
+	cfg     config.Pool
+	tracers []pgx.QueryTracer
+	pool    atomic.Pointer[pgxpool.Pool]
+	// index is the position of the current pool in urls, only touched by
+	// the Watch goroutine after NewFailover returns.
+	index int
+}
+
+// NewFailover connects to the first of urls that answers, trying them all
+// with backoff until cfg.ConnectRetryWindow elapses, since the databases
+// often start after the API.
+func NewFailover(ctx context.Context, urls []string, cfg config.Pool, tracers ...pgx.QueryTracer) (*Failover, error) {
+	if len(urls) == 0 {
+		return nil, errNoDatabase
+	}
+
+	f := &Failover{urls: urls, cfg: cfg, tracers: tracers}
+
+	err := retryWithBackoff(ctx, cfg.ConnectRetryWindow, func() error {
+		return f.promote(ctx, 0)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to ping database: %w", err)
+	}
+
+	return f, nil
+}
+
+// Pool returns the pool of the current primary.
+func (f *Failover) Pool() *pgxpool.Pool {
+	return f.pool.Load()
+}
+
+// Ping pings the current primary.
+func (f *Failover) Ping(ctx context.Context) error {
+	return f.Pool().Ping(ctx)
+}
+
+// Stat returns the statistics of the current primary's pool.
+func (f *Failover) Stat() *pgxpool.Stat {
+	return f.Pool().Stat()
+}
+
+// Acquire takes a connection from the current primary's pool.
+func (f *Failover) Acquire(ctx context.Context) (*pgxpool.Conn, error) {
+	return f.Pool().Acquire(ctx)
+}
+
+// Close closes the current primary's pool.
+func (f *Failover) Close() {
+	f.Pool().Close()
+}
+
+// Watch pings the primary every interval and, after failoverAfter
+// consecutive failures, promotes the next database in order that answers.
+// The primary is kept when none does, to be retried on the next failure.
+// It returns when ctx is done, right away when there is nothing to fail
+// over to.
+func (f *Failover) Watch(ctx context.Context, interval time.Duration) {
+	if len(f.urls) < 2 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		err := f.Ping(pingCtx)
+		cancel()
+
+		if err == nil {
+			failures = 0
+			continue
+		}
+
+		failures++
+		slog.Warn("Primary database ping failed", "database", f.index, "failures", failures, "error", err)
+
+		if failures < failoverAfter {
+			continue
+		}
+
+		err = f.promote(ctx, f.index+1)
+		if err != nil {
+			slog.Error("No database to fail over to", "error", err)
+			continue
+		}
+
+		failures = 0
+	}
+}
+
+// promote connects to the databases in order starting at from, wrapping
+// around, and makes the first that answers the primary, closing the one it
+// replaces.
+func (f *Failover) promote(ctx context.Context, from int) error {
+	var errs []error
+
+	for n := range f.urls {
+		i := (from + n) % len(f.urls)
+		if f.Pool() != nil && i == f.index {
+			continue
+		}
+
+		pool, err := f.connect(ctx, f.urls[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("database %d: %w", i, err))
+			continue
+		}
+
+		old := f.pool.Swap(pool)
+		f.index = i
+
+		if old != nil {
+			slog.Warn("Promoted database", "database", i)
+			// Close waits for acquired connections to be released.
+			go old.Close()
+		}
+
+		return nil
+	}
+
+	return errors.Join(errs...)
+}
+
+func (f *Failover) connect(ctx context.Context, databaseURL string) (*pgxpool.Pool, error) {
+	pool, err := newPool(ctx, databaseURL, f.cfg, f.tracers)
+	if err != nil {
+		return nil, err
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, f.cfg.ConnectTimeout)
+	defer cancel()
+
+	err = pool.Ping(pingCtx)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNewFailoverWithoutURLs(t *testing.T) {
+	f, err := NewFailover(context.Background(), nil, testPool)
+	if !errors.Is(err, errNoDatabase) || f != nil {
+		t.Fatalf("got %v, %v; want errNoDatabase", f, err)
+	}
+}
+
+func TestNewFailoverUnreachable(t *testing.T) {
+	f, err := NewFailover(context.Background(), []string{"postgres://user@127.0.0.1:1/db"}, testPool)
+	if err == nil || f != nil {
+		t.Fatalf("got %v, %v; want an error", f, err)
+	}
+}
+
+func TestFailoverPromotesNextDatabase(t *testing.T) {
+	url := testDatabaseURL(t)
+
+	f, err := NewFailover(context.Background(), []string{"postgres://user@127.0.0.1:1/db", url}, testPool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.index != 1 {
+		t.Fatalf("connected to database %d, want 1", f.index)
+	}
+
+	err = f.Ping(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Memory keeps clients and transactions in maps, for tests and for running
+// the API without Postgres. Each client has its own lock, so operations on
+// different clients run in parallel.
+type Memory struct {
+	// mu guards the clients map, and is held shared for the whole of every
+	// other operation so Snapshot, holding it exclusively, never sees one
+	// half done.
+	mu      sync.RWMutex
+	clients map[int]*memoryClient
+	lastID  atomic.Int64
+}
+
+type memoryClient struct {
+	mu           sync.Mutex
+	client       Client
+	transactions []Transaction
+	// idempotent holds the result of each transaction created with an
+	// idempotency key.
+	idempotent map[string]idempotentResult
+}
+
+type idempotentResult struct {
+	Client      Client      `json:"client"`
+	Transaction Transaction `json:"transaction"`
+}
+
+func NewMemory(clients []Client) *Memory {
+	m := &Memory{clients: make(map[int]*memoryClient, len(clients))}
+
+	for _, c := range clients {
+		m.clients[c.ID] = &memoryClient{client: c}
+	}
+
+	return m
+}
+
+// lock returns the client with its lock held, along with the function
+// releasing it and m.mu.
+func (m *Memory) lock(id int) (*memoryClient, func(), error) {
+	m.mu.RLock()
+
+	c, ok := m.clients[id]
+	if !ok {
+		m.mu.RUnlock()
+		return nil, nil, ErrClientNotFound
+	}
+
+	c.mu.Lock()
+	return c, func() {
+		c.mu.Unlock()
+		m.mu.RUnlock()
+	}, nil
+}
+
+func (m *Memory) ClientIDs(ctx context.Context) ([]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]int, 0, len(m.clients))
+	for id := range m.clients {
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (m *Memory) Clients(ctx context.Context) ([]Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clients := make([]Client, 0, len(m.clients))
+	for _, c := range m.clients {
+		c.mu.Lock()
+		clients = append(clients, c.client)
+		c.mu.Unlock()
+	}
+
+	return clients, nil
+}
+
+func (m *Memory) FindClient(ctx context.Context, id int) (Client, error) {
+	c, unlock, err := m.lock(id)
+	if err != nil {
+		return Client{}, err
+	}
+	defer unlock()
+
+	return c.client, nil
+}
+
+func (m *Memory) CreateClient(ctx context.Context, c Client) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.clients[c.ID]; ok {
+		return ErrClientExists
+	}
+
+	m.clients[c.ID] = &memoryClient{client: c}
+	return nil
+}
+
+func (m *Memory) UpdateLimit(ctx context.Context, id int, limit int) (Client, error) {
+	c, unlock, err := m.lock(id)
+	if err != nil {
+		return Client{}, err
+	}
+	defer unlock()
+
+	if c.client.Balance < -limit {
+		return Client{}, ErrLimitExceeded
+	}
+
+	c.client.Limit = limit
+	return c.client, nil
+}
+
+func (m *Memory) DeleteClient(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.clients[id]; !ok {
+		return ErrClientNotFound
+	}
+
+	delete(m.clients, id)
+	return nil
+}
+
+func (m *Memory) ApplyTransaction(ctx context.Context, clientID int, t Transaction) (Client, Transaction, error) {
+	c, unlock, err := m.lock(clientID)
+	if err != nil {
+		return Client{}, Transaction{}, err
+	}
+	defer unlock()
+
+	if stored, ok := c.idempotent[t.IdempotencyKey]; ok && t.IdempotencyKey != "" {
+		return stored.Client, stored.Transaction, nil
+	}
+
+	balance, err := balanceAfter(c.client, t)
+	if err != nil {
+		return Client{}, Transaction{}, err
+	}
+
+	c.client.Balance = balance
+	t = m.record(c, t)
+
+	if t.IdempotencyKey != "" {
+		if c.idempotent == nil {
+			c.idempotent = make(map[string]idempotentResult)
+		}
+		c.idempotent[t.IdempotencyKey] = idempotentResult{c.client, t}
+	}
+
+	return c.client, t, nil
+}
+
+func (m *Memory) ApplyTransactions(ctx context.Context, clientID int, ts []Transaction) ([]Client, error) {
+	c, unlock, err := m.lock(clientID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	results := make([]Client, len(ts))
+	client := c.client
+	for i, t := range ts {
+		client.Balance, err = balanceAfter(client, t)
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+
+		results[i] = client
+	}
+
+	c.client = client
+	for _, t := range ts {
+		m.record(c, t)
+	}
+
+	return results, nil
+}
+
+// Transfer locks both clients in id order, so opposite transfers can't
+// deadlock.
+func (m *Memory) Transfer(ctx context.Context, fromID, toID int, t Transaction) (Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	from, ok := m.clients[fromID]
+	if !ok {
+		return Client{}, ErrClientNotFound
+	}
+
+	to, ok := m.clients[toID]
+	if !ok || to == from {
+		return Client{}, ErrClientNotFound
+	}
+
+	first, second := from, to
+	if toID < fromID {
+		first, second = to, from
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	balance, err := balanceAfter(from.client, Transaction{Amount: t.Amount, Type: "d"})
+	if err != nil {
+		return Client{}, err
+	}
+
+	from.client.Balance = balance
+	to.client.Balance += t.Amount
+
+	// Both ids are taken up front so each side can point at the other.
+	creditID := m.lastID.Add(2)
+	debit, credit := t, t
+	debit.Type, credit.Type = "d", "c"
+	debit.ID, credit.ID = creditID-1, creditID
+	debit.LinkedID, credit.LinkedID = credit.ID, debit.ID
+	debit.CreatedAt = time.Now()
+	credit.CreatedAt = debit.CreatedAt
+	from.transactions = append(from.transactions, debit)
+	to.transactions = append(to.transactions, credit)
+
+	return from.client, nil
+}
+
+func (m *Memory) Reverse(ctx context.Context, clientID int, transactionID int64) (Client, error) {
+	c, unlock, err := m.lock(clientID)
+	if err != nil {
+		return Client{}, err
+	}
+	defer unlock()
+
+	var original *Transaction
+	for i, t := range c.transactions {
+		if t.ReversalOf == transactionID {
+			return Client{}, ErrAlreadyReversed
+		}
+
+		if t.ID == transactionID {
+			original = &c.transactions[i]
+		}
+	}
+
+	if original == nil {
+		return Client{}, ErrTransactionNotFound
+	}
+
+	if original.ReversalOf != 0 {
+		return Client{}, ErrNotReversible
+	}
+
+	reversal := Transaction{
+		Amount:      original.Amount,
+		Type:        "d",
+		Description: ReversalDescription,
+		ReversalOf:  transactionID,
+	}
+	if original.Type == "d" {
+		reversal.Type = "c"
+	}
+
+	balance, err := balanceAfter(c.client, reversal)
+	if err != nil {
+		return Client{}, err
+	}
+
+	c.client.Balance = balance
+	m.record(c, reversal)
+
+	return c.client, nil
+}
+
+func (m *Memory) FindTransaction(ctx context.Context, clientID int, id int64) (Transaction, error) {
+	c, unlock, err := m.lock(clientID)
+	if err != nil {
+		return Transaction{}, ErrTransactionNotFound
+	}
+	defer unlock()
+
+	for _, t := range c.transactions {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+
+	return Transaction{}, ErrTransactionNotFound
+}
+
+func (m *Memory) EachTransaction(ctx context.Context, clientID int, fn func(Transaction) error) error {
+	c, unlock, err := m.lock(clientID)
+	if err != nil {
+		return err
+	}
+	all := slices.Clone(c.transactions)
+	unlock()
+
+	for _, t := range all {
+		err := fn(t)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// record assigns t the next id and appends it to the client's ledger. The
+// caller must hold c.mu. Ids grow with every call, so each ledger stays in
+// id order.
+func (m *Memory) record(c *memoryClient, t Transaction) Transaction {
+	t.ID = m.lastID.Add(1)
+	t.CreatedAt = time.Now()
+	c.transactions = append(c.transactions, t)
+
+	return t
+}
+
+func (m *Memory) Statement(ctx context.Context, clientID int, q StatementQuery) (Client, []Transaction, error) {
+	c, unlock, err := m.lock(clientID)
+	if err != nil {
+		return Client{}, nil, err
+	}
+	defer unlock()
+
+	all := c.transactions
+	latest := make([]Transaction, 0, q.Limit)
+	skipped := 0
+	balance := c.client.Balance
+	for i := len(all) - 1; i >= 0 && len(latest) < q.Limit; i-- {
+		t := all[i]
+		t.BalanceAfter = balance
+
+		if t.Type == "d" {
+			balance += t.Amount
+		} else {
+			balance -= t.Amount
+		}
+
+		if !q.matches(t) {
+			continue
+		}
+
+		if skipped < q.Offset {
+			skipped++
+			continue
+		}
+
+		latest = append(latest, t)
+	}
+
+	result := c.client
+	if len(all) > 0 {
+		result.LastTransactionID = all[len(all)-1].ID
+	}
+
+	return result, latest, nil
+}
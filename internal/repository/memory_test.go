@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	testRepository(t, func(t *testing.T) store {
+		return NewMemory(testClients)
+	})
+}
+
+func TestMemoryEachTransactionUnknownClient(t *testing.T) {
+	repo := NewMemory(testClients)
+
+	err := repo.EachTransaction(context.Background(), 99, func(Transaction) error { return nil })
+	if !errors.Is(err, ErrClientNotFound) {
+		t.Fatalf("got %v, want ErrClientNotFound", err)
+	}
+}
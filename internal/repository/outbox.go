@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Event types, as stored in the outbox.
+const (
+	// TransactionCreatedEvent is queued for every ledger row.
+	TransactionCreatedEvent = "TransactionCreated"
+	// LimitChangedEvent is queued when a client's limit is updated.
+	LimitChangedEvent = "LimitChanged"
+)
+
+// event is a payload queued in the outbox.
+type event interface {
+	eventType() string
+	clientID() int
+}
+
+// TransactionCreated is the payload of a TransactionCreatedEvent, with the
+// client's balance and limit right after the transaction.
+type TransactionCreated struct {
+	ID          int64     `json:"id"`
+	ClientID    int       `json:"client_id"`
+	Amount      int       `json:"valor"`
+	Type        string    `json:"tipo"`
+	Description string    `json:"descricao"`
+	CreatedAt   time.Time `json:"realizada_em"`
+	Balance     int       `json:"saldo"`
+	Limit       int       `json:"limite"`
+}
+
+func (e TransactionCreated) eventType() string { return TransactionCreatedEvent }
+func (e TransactionCreated) clientID() int     { return e.ClientID }
+
+func transactionCreated(c Client, t Transaction) TransactionCreated {
+	return TransactionCreated{
+		ID:          t.ID,
+		ClientID:    c.ID,
+		Amount:      t.Amount,
+		Type:        t.Type,
+		Description: t.Description,
+		CreatedAt:   t.CreatedAt,
+		Balance:     c.Balance,
+		Limit:       c.Limit,
+	}
+}
+
+// LimitChanged is the payload of a LimitChangedEvent.
+type LimitChanged struct {
+	ClientID int `json:"client_id"`
+	Limit    int `json:"limite"`
+	Balance  int `json:"saldo"`
+}
+
+func (e LimitChanged) eventType() string { return LimitChangedEvent }
+func (e LimitChanged) clientID() int     { return e.ClientID }
+
+// Event is an event waiting in the outbox to be published.
+type Event struct {
+	ID       int64
+	ClientID int
+	Type     string
+	Payload  []byte
+}
+
+// UseOutbox makes every write queue its events in bank.outbox, in the same
+// transaction as the change they describe, from now on.
+func (p *Postgres) UseOutbox() {
+	p.outbox = true
+}
+
+// enqueue writes events to the outbox, and queues their deliveries to the
+// matching webhooks, within tx, when each is enabled.
+func (p *Postgres) enqueue(ctx context.Context, tx pgx.Tx, events ...event) error {
+	if !p.outbox && !p.webhooks {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, e := range events {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		if p.outbox {
+			batch.Queue(
+				`INSERT INTO bank.outbox (client_id, "type", payload) VALUES ($1, $2, $3)`,
+				e.clientID(),
+				e.eventType(),
+				payload,
+			)
+		}
+
+		if p.webhooks {
+			batch.Queue(
+				`INSERT INTO bank.webhook_deliveries (webhook_id, "type", payload)
+				SELECT id, $2, $3 FROM bank.webhooks WHERE client_id IS NULL OR client_id = $1`,
+				e.clientID(),
+				e.eventType(),
+				payload,
+			)
+		}
+	}
+
+	return tx.SendBatch(ctx, batch).Close()
+}
+
+// RelayOutbox hands up to limit of the oldest pending events to publish and
+// deletes them once it succeeds, returning how many it relayed. When
+// publish fails the events stay pending, so every event is published at
+// least once; consumers drop duplicates by id. Events of one client are
+// always relayed in order, since its writes are serialized.
+func (p *Postgres) RelayOutbox(ctx context.Context, limit int, publish func(context.Context, []Event) error) (int, error) {
+	var relayed int
+
+	err := pgx.BeginTxFunc(ctx, p.pool(), pgx.TxOptions{}, func(tx pgx.Tx) error {
+		// SKIP LOCKED lets writers keep inserting while a batch is out.
+		rows, err := tx.Query(ctx,
+			`SELECT id, client_id, "type", payload FROM bank.outbox ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`,
+			limit,
+		)
+		if err != nil {
+			return err
+		}
+
+		events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Event, error) {
+			var e Event
+			err := row.Scan(&e.ID, &e.ClientID, &e.Type, &e.Payload)
+			return e, err
+		})
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		err = publish(ctx, events)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, len(events))
+		for i, e := range events {
+			ids[i] = e.ID
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM bank.outbox WHERE id = ANY($1)", ids)
+		relayed = len(events)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return relayed, nil
+}
+
+// RelayOutbox relays up to limit events from each shard in turn.
+func (s *Sharded) RelayOutbox(ctx context.Context, limit int, publish func(context.Context, []Event) error) (int, error) {
+	var relayed int
+	for _, shard := range s.shards {
+		n, err := shard.RelayOutbox(ctx, limit, publish)
+		relayed += n
+		if err != nil {
+			return relayed, err
+		}
+	}
+
+	return relayed, nil
+}
@@ -0,0 +1,785 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/config"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var isolationLevels = map[string]pgx.TxIsoLevel{
+	"read committed":  pgx.ReadCommitted,
+	"repeatable read": pgx.RepeatableRead,
+	"serializable":    pgx.Serializable,
+}
+
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// clientLockClass is the advisory lock class of the per-client locks; the
+// key is the client id. Class 1 holds the app-wide locks.
+const clientLockClass = 2
+
+type Postgres struct {
+	primary *Failover
+	// replica, when set, serves statements, falling back to the primary
+	// when it fails.
+	replica   *pgxpool.Pool
+	txOptions pgx.TxOptions
+	// advisoryLocks serializes writes per client with pg_advisory_xact_lock
+	// before the client row is touched.
+	advisoryLocks bool
+	// outbox queues an event with every ledger row.
+	outbox bool
+	// webhooks queues a delivery of each event to the matching webhooks.
+	webhooks bool
+}
+
+// NewReplicaPool creates a pool for a read replica. Unlike NewFailover it
+// doesn't wait for the database, since reads fall back to the primary
+// while the replica is down.
+func NewReplicaPool(ctx context.Context, databaseURL string, cfg config.Pool, tracers ...pgx.QueryTracer) (*pgxpool.Pool, error) {
+	return newPool(ctx, databaseURL, cfg, tracers)
+}
+
+func newPool(ctx context.Context, databaseURL string, cfg config.Pool, tracers []pgx.QueryTracer) (*pgxpool.Pool, error) {
+	dbConfig, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create a config: %w", err)
+	}
+
+	dbConfig.MaxConns = cfg.MaxConns
+	dbConfig.MinConns = cfg.MinConns
+	dbConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	dbConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	dbConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	dbConfig.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+
+	if cfg.StatementTimeout > 0 {
+		dbConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	if cfg.LockTimeout > 0 {
+		dbConfig.ConnConfig.RuntimeParams["lock_timeout"] = strconv.FormatInt(cfg.LockTimeout.Milliseconds(), 10)
+	}
+
+	execMode, ok := queryExecModes[cfg.QueryExecMode]
+	if !ok {
+		return nil, fmt.Errorf("Invalid query exec mode: %s", cfg.QueryExecMode)
+	}
+	dbConfig.ConnConfig.DefaultQueryExecMode = execMode
+
+	// Named prepared statements outlive the transaction, which a
+	// transaction-pooling proxy cannot keep on one server connection.
+	prepare := execMode == pgx.QueryExecModeCacheStatement
+	notify := cfg.NotifyClientChanges
+	if prepare || notify {
+		dbConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			// Set rather than sent on connect, which PgBouncer would
+			// refuse as an unknown parameter.
+			if notify {
+				_, err := conn.Exec(ctx, "SET bank.notify_client_changes = on")
+				if err != nil {
+					return err
+				}
+			}
+			if prepare {
+				return prepareHotQueries(ctx, conn)
+			}
+
+			return nil
+		}
+	}
+
+	switch len(tracers) {
+	case 0:
+	case 1:
+		// Kept as is so optional interfaces like pgx.BatchTracer still work.
+		dbConfig.ConnConfig.Tracer = tracers[0]
+	default:
+		dbConfig.ConnConfig.Tracer = queryTracers(tracers)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, dbConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create connection pool: %w", err)
+	}
+
+	return pool, nil
+}
+
+// retryWithBackoff calls fn with exponential backoff until it succeeds or
+// window elapses.
+func retryWithBackoff(ctx context.Context, window time.Duration, fn func() error) error {
+	deadline := time.Now().Add(window)
+	backoff := 100 * time.Millisecond
+
+	for {
+		err := fn()
+		if err == nil || time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+
+		slog.Warn("Database unavailable, retrying", "in", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
+
+func NewPostgres(primary *Failover, isolation string, advisoryLocks bool) (*Postgres, error) {
+	isoLevel, ok := isolationLevels[isolation]
+	if !ok {
+		return nil, fmt.Errorf("Invalid transaction isolation: %s", isolation)
+	}
+
+	return &Postgres{
+		primary:       primary,
+		txOptions:     pgx.TxOptions{IsoLevel: isoLevel},
+		advisoryLocks: advisoryLocks,
+	}, nil
+}
+
+// UseReplica sends statements to the replica pool from now on. The pool is
+// closed by Close.
+func (p *Postgres) UseReplica(replica *pgxpool.Pool) {
+	p.replica = replica
+}
+
+// Close closes the replica pool, if any. The primary belongs to the
+// caller.
+func (p *Postgres) Close() {
+	if p.replica != nil {
+		p.replica.Close()
+	}
+}
+
+// pool returns the current primary pool, which changes on failover.
+func (p *Postgres) pool() *pgxpool.Pool {
+	return p.primary.Pool()
+}
+
+// SchemaExists reports whether the bank schema has been created.
+func (p *Postgres) SchemaExists(ctx context.Context) (bool, error) {
+	var exists bool
+	err := p.pool().QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM information_schema.schemata WHERE schema_name = 'bank')",
+	).Scan(&exists)
+
+	return exists, err
+}
+
+// Seed inserts the given clients, leaving existing ones untouched.
+func (p *Postgres) Seed(ctx context.Context, clients []Client) error {
+	batch := &pgx.Batch{}
+	for _, c := range clients {
+		batch.Queue(
+			`INSERT INTO bank.clients (id, "limit", balance) VALUES ($1, $2, $3) ON CONFLICT (id) DO NOTHING`,
+			c.ID,
+			c.Limit,
+			c.Balance,
+		)
+	}
+
+	return p.pool().SendBatch(ctx, batch).Close()
+}
+
+func (p *Postgres) ClientIDs(ctx context.Context) ([]int, error) {
+	rows, err := p.pool().Query(ctx, "SELECT id FROM bank.clients")
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
+
+func (p *Postgres) Clients(ctx context.Context) ([]Client, error) {
+	rows, err := p.pool().Query(ctx, `SELECT id, "limit", balance FROM bank.clients`)
+	if err != nil {
+		return nil, err
+	}
+
+	clientRows, err := pgx.CollectRows(rows, pgx.RowToStructByName[clientRow])
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make([]Client, len(clientRows))
+	for i, row := range clientRows {
+		clients[i] = row.client()
+	}
+
+	return clients, nil
+}
+
+func (p *Postgres) FindClient(ctx context.Context, id int) (Client, error) {
+	client := Client{ID: id}
+	err := withRetry(ctx, func() error {
+		return p.pool().QueryRow(ctx, findClientSQL, id).Scan(&client.Limit, &client.Balance)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Client{}, ErrClientNotFound
+	}
+
+	return client, err
+}
+
+func (p *Postgres) CreateClient(ctx context.Context, c Client) error {
+	_, err := p.pool().Exec(ctx,
+		`INSERT INTO bank.clients (id, "limit", balance) VALUES ($1, $2, $3)`,
+		c.ID,
+		c.Limit,
+		c.Balance,
+	)
+
+	if isUniqueViolation(err) {
+		return ErrClientExists
+	}
+
+	return err
+}
+
+func (p *Postgres) UpdateLimit(ctx context.Context, id int, limit int) (Client, error) {
+	client := Client{ID: id}
+	err := p.inTx(ctx, []int{id}, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx,
+			`UPDATE bank.clients SET "limit" = $2 WHERE id = $1 RETURNING "limit", balance`,
+			id,
+			limit,
+		).Scan(&client.Limit, &client.Balance)
+		if err != nil {
+			return err
+		}
+
+		return p.enqueue(ctx, tx, LimitChanged{ClientID: id, Limit: client.Limit, Balance: client.Balance})
+	})
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Client{}, ErrClientNotFound
+		}
+
+		if isCheckViolation(err) {
+			return Client{}, ErrLimitExceeded
+		}
+
+		return Client{}, err
+	}
+
+	return client, nil
+}
+
+func (p *Postgres) DeleteClient(ctx context.Context, id int) error {
+	return pgx.BeginFunc(ctx, p.pool(), func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "DELETE FROM bank.transactions WHERE client_id = $1", id)
+		if err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, "DELETE FROM bank.clients WHERE id = $1", id)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrClientNotFound
+		}
+
+		return nil
+	})
+}
+
+func (p *Postgres) ApplyTransaction(ctx context.Context, clientID int, t Transaction) (Client, Transaction, error) {
+	client := Client{ID: clientID}
+
+	err := p.inTx(ctx, []int{clientID}, func(tx pgx.Tx) error {
+		if t.IdempotencyKey != "" {
+			tag, err := tx.Exec(ctx,
+				`INSERT INTO bank.idempotency_keys (client_id, "key") VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+				clientID,
+				t.IdempotencyKey,
+			)
+			if err != nil {
+				return err
+			}
+
+			if tag.RowsAffected() == 0 {
+				return tx.QueryRow(ctx,
+					`
+					    SELECT k.balance, k."limit", COALESCE(t.id, 0), COALESCE(t.created_at, k.created_at)
+					    FROM bank.idempotency_keys k
+					    LEFT JOIN bank.transactions t ON t.id = k.transaction_id
+					    WHERE k.client_id = $1 AND k."key" = $2
+					  `,
+					clientID,
+					t.IdempotencyKey,
+				).Scan(&client.Balance, &client.Limit, &t.ID, &t.CreatedAt)
+			}
+		}
+
+		// The status is always ok: debits past the limit fail on
+		// clients_balance_check instead.
+		err := tx.QueryRow(ctx, processTransactionSQL,
+			clientID,
+			t.Amount,
+			t.Type,
+			t.Description,
+		).Scan(&client.Balance, &client.Limit, nil, &t.ID, &t.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		err = p.enqueue(ctx, tx, transactionCreated(client, t))
+		if err != nil {
+			return err
+		}
+
+		if t.IdempotencyKey == "" {
+			return nil
+		}
+
+		_, err = tx.Exec(ctx,
+			`UPDATE bank.idempotency_keys SET balance = $3, "limit" = $4, transaction_id = $5 WHERE client_id = $1 AND "key" = $2`,
+			clientID,
+			t.IdempotencyKey,
+			client.Balance,
+			client.Limit,
+			t.ID,
+		)
+		return err
+	})
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Client{}, Transaction{}, ErrClientNotFound
+		}
+
+		if isCheckViolation(err) {
+			return Client{}, Transaction{}, ErrLimitExceeded
+		}
+
+		if isForeignKeyViolation(err) {
+			return Client{}, Transaction{}, ErrClientNotFound
+		}
+
+		return Client{}, Transaction{}, err
+	}
+
+	return client, t, nil
+}
+
+func (p *Postgres) ApplyTransactions(ctx context.Context, clientID int, ts []Transaction) ([]Client, error) {
+	var results []Client
+
+	err := p.inTx(ctx, []int{clientID}, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		for _, t := range ts {
+			batch.Queue(
+				"SELECT new_balance, client_limit, transaction_id, transaction_created_at FROM bank.process_transaction($1, $2, $3, $4)",
+				clientID,
+				t.Amount,
+				t.Type,
+				t.Description,
+			)
+		}
+
+		br := tx.SendBatch(ctx, batch)
+		defer br.Close()
+
+		results = make([]Client, len(ts))
+		events := make([]event, len(ts))
+		for i, t := range ts {
+			results[i].ID = clientID
+
+			err := br.QueryRow().Scan(&results[i].Balance, &results[i].Limit, &t.ID, &t.CreatedAt)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return ErrClientNotFound
+				}
+
+				if isCheckViolation(err) {
+					return &BatchError{Index: i, Err: ErrLimitExceeded}
+				}
+
+				return err
+			}
+
+			events[i] = transactionCreated(results[i], t)
+		}
+
+		err := br.Close()
+		if err != nil {
+			return err
+		}
+
+		return p.enqueue(ctx, tx, events...)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Transfer debits fromID and credits toID by t.Amount in one transaction,
+// writing a ledger row for each side linked to the other. Client rows are
+// locked in id order so opposite transfers can't deadlock.
+func (p *Postgres) Transfer(ctx context.Context, fromID, toID int, t Transaction) (Client, error) {
+	client := Client{ID: fromID}
+
+	err := p.inTx(ctx, []int{fromID, toID}, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx,
+			"SELECT id FROM bank.clients WHERE id = ANY($1) ORDER BY id FOR UPDATE",
+			[]int{fromID, toID},
+		)
+		if err != nil {
+			return err
+		}
+
+		locked, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+
+		if len(locked) != 2 {
+			return ErrClientNotFound
+		}
+
+		err = tx.QueryRow(ctx,
+			`UPDATE bank.clients SET balance = balance - $2 WHERE id = $1 RETURNING "limit", balance`,
+			fromID,
+			t.Amount,
+		).Scan(&client.Limit, &client.Balance)
+		if err != nil {
+			return err
+		}
+
+		to := Client{ID: toID}
+		err = tx.QueryRow(ctx,
+			`UPDATE bank.clients SET balance = balance + $2 WHERE id = $1 RETURNING "limit", balance`,
+			toID,
+			t.Amount,
+		).Scan(&to.Limit, &to.Balance)
+		if err != nil {
+			return err
+		}
+
+		debit := Transaction{Amount: t.Amount, Type: "d", Description: t.Description}
+		err = tx.QueryRow(ctx,
+			`INSERT INTO bank.transactions (client_id, amount, description, "type", created_at)
+			VALUES ($1, $2, $3, 'd', now()) RETURNING id, created_at`,
+			fromID,
+			t.Amount,
+			t.Description,
+		).Scan(&debit.ID, &debit.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		credit := Transaction{Amount: t.Amount, Type: "c", Description: t.Description}
+		err = tx.QueryRow(ctx,
+			`INSERT INTO bank.transactions (client_id, amount, description, "type", created_at, linked_id)
+			VALUES ($1, $2, $3, 'c', now(), $4) RETURNING id, created_at`,
+			toID,
+			t.Amount,
+			t.Description,
+			debit.ID,
+		).Scan(&credit.ID, &credit.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE bank.transactions SET linked_id = $2 WHERE id = $1", debit.ID, credit.ID)
+		if err != nil {
+			return err
+		}
+
+		return p.enqueue(ctx, tx, transactionCreated(client, debit), transactionCreated(to, credit))
+	})
+
+	if err != nil {
+		if isCheckViolation(err) {
+			return Client{}, ErrLimitExceeded
+		}
+
+		return Client{}, err
+	}
+
+	return client, nil
+}
+
+func (p *Postgres) Reverse(ctx context.Context, clientID int, transactionID int64) (Client, error) {
+	client := Client{ID: clientID}
+
+	err := p.inTx(ctx, []int{clientID}, func(tx pgx.Tx) error {
+		var original Transaction
+		var reversalOf *int64
+
+		err := tx.QueryRow(ctx,
+			`SELECT amount, "type", reversal_of FROM bank.transactions WHERE id = $1 AND client_id = $2`,
+			transactionID,
+			clientID,
+		).Scan(&original.Amount, &original.Type, &reversalOf)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTransactionNotFound
+			}
+
+			return err
+		}
+
+		if reversalOf != nil {
+			return ErrNotReversible
+		}
+
+		reversal := Transaction{Amount: original.Amount, Type: "d", Description: ReversalDescription}
+		delta := -original.Amount
+		if original.Type == "d" {
+			reversal.Type = "c"
+			delta = original.Amount
+		}
+
+		err = tx.QueryRow(ctx,
+			`UPDATE bank.clients SET balance = balance + $2 WHERE id = $1 RETURNING "limit", balance`,
+			clientID,
+			delta,
+		).Scan(&client.Limit, &client.Balance)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(ctx,
+			`INSERT INTO bank.transactions (client_id, amount, description, "type", created_at, reversal_of)
+			VALUES ($1, $2, $3, $4, now(), $5) RETURNING id, created_at`,
+			clientID,
+			reversal.Amount,
+			reversal.Description,
+			reversal.Type,
+			transactionID,
+		).Scan(&reversal.ID, &reversal.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		return p.enqueue(ctx, tx, transactionCreated(client, reversal))
+	})
+
+	if err != nil {
+		if isCheckViolation(err) {
+			return Client{}, ErrLimitExceeded
+		}
+
+		if isUniqueViolation(err) {
+			return Client{}, ErrAlreadyReversed
+		}
+
+		return Client{}, err
+	}
+
+	return client, nil
+}
+
+func (p *Postgres) FindTransaction(ctx context.Context, clientID int, id int64) (Transaction, error) {
+	tr := Transaction{ID: id}
+	err := p.pool().QueryRow(ctx,
+		`SELECT amount, description, "type", created_at FROM bank.transactions WHERE id = $1 AND client_id = $2`,
+		id,
+		clientID,
+	).Scan(&tr.Amount, &tr.Description, &tr.Type, &tr.CreatedAt)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Transaction{}, ErrTransactionNotFound
+	}
+
+	return tr, err
+}
+
+func (p *Postgres) EachTransaction(ctx context.Context, clientID int, fn func(Transaction) error) error {
+	rows, err := p.pool().Query(ctx,
+		`SELECT id, amount, description, "type", created_at FROM bank.transactions WHERE client_id = $1 ORDER BY id`,
+		clientID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := pgx.RowToStructByName[transactionRow](rows)
+		if err != nil {
+			return err
+		}
+
+		err = fn(row.transaction())
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// statementFilter returns the extra transaction predicates q asks for, with
+// their args appended after the given ones.
+func statementFilter(q StatementQuery, args ...any) (string, []any) {
+	var filter strings.Builder
+
+	if !q.From.IsZero() {
+		args = append(args, q.From)
+		fmt.Fprintf(&filter, " AND created_at >= $%d", len(args))
+	}
+
+	if !q.To.IsZero() {
+		args = append(args, q.To)
+		fmt.Fprintf(&filter, " AND created_at <= $%d", len(args))
+	}
+
+	if q.Type != "" {
+		args = append(args, q.Type)
+		fmt.Fprintf(&filter, ` AND "type" = $%d`, len(args))
+	}
+
+	if q.Before != 0 {
+		args = append(args, q.Before)
+		fmt.Fprintf(&filter, " AND id < $%d", len(args))
+	}
+
+	return filter.String(), args
+}
+
+// inTx runs fn in a transaction with the configured isolation level,
+// retrying on serialization and transient failures. When advisory locks are
+// enabled, it takes one per client id, in ascending order, before calling
+// fn. Lock and statement timeouts are reported as ErrBusy.
+func (p *Postgres) inTx(ctx context.Context, clientIDs []int, fn func(tx pgx.Tx) error) error {
+	ids := slices.Clone(clientIDs)
+	slices.Sort(ids)
+
+	err := withRetry(ctx, func() error {
+		return pgx.BeginTxFunc(ctx, p.pool(), p.txOptions, func(tx pgx.Tx) error {
+			if p.advisoryLocks {
+				for _, id := range ids {
+					_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, $2)", clientLockClass, id)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return fn(tx)
+		})
+	})
+	if isTimeout(err) {
+		return fmt.Errorf("%w: %w", ErrBusy, err)
+	}
+
+	return err
+}
+
+// Statement selects the page with statementTransactionsSQL, where each
+// balance_after is the current balance minus the sum of every newer
+// transaction, filtered out or not, so it stays right for filtered pages
+// too.
+func (p *Postgres) Statement(ctx context.Context, clientID int, q StatementQuery) (Client, []Transaction, error) {
+	if p.replica != nil {
+		client, transactions, err := p.statement(ctx, p.replica, clientID, q)
+		if err == nil || errors.Is(err, ErrClientNotFound) || ctx.Err() != nil {
+			return client, transactions, err
+		}
+
+		slog.Warn("Read replica failed, using the primary", "error", err)
+	}
+
+	var client Client
+	var transactions []Transaction
+
+	err := withRetry(ctx, func() error {
+		var err error
+		client, transactions, err = p.statement(ctx, p.pool(), clientID, q)
+		return err
+	})
+
+	return client, transactions, err
+}
+
+func (p *Postgres) statement(ctx context.Context, pool *pgxpool.Pool, clientID int, q StatementQuery) (Client, []Transaction, error) {
+	filter, args := statementFilter(q, clientID, q.Limit, q.Offset)
+
+	// Both queries go in one round trip. Repeatable read gives them the
+	// same snapshot, so the balance matches the transactions summed off
+	// it.
+	batch := &pgx.Batch{}
+	batch.Queue("BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY")
+	batch.Queue(statementClientSQL, clientID)
+	batch.Queue(statementTransactionsSQL(filter), args...)
+	batch.Queue("COMMIT")
+
+	results := pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	_, err := results.Exec()
+	if err != nil {
+		return Client{}, nil, err
+	}
+
+	rows, err := results.Query()
+	if err != nil {
+		return Client{}, nil, err
+	}
+
+	clientRow, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[statementClientRow])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Client{}, nil, ErrClientNotFound
+	}
+	if err != nil {
+		return Client{}, nil, err
+	}
+
+	rows, err = results.Query()
+	if err != nil {
+		return Client{}, nil, err
+	}
+
+	statementRows, err := pgx.CollectRows(rows, pgx.RowToStructByName[statementRow])
+	if err != nil {
+		return Client{}, nil, err
+	}
+
+	client := Client{
+		ID:                clientID,
+		Limit:             clientRow.Limit,
+		Balance:           clientRow.Balance,
+		LastTransactionID: clientRow.LastTransactionID,
+	}
+
+	transactions := make([]Transaction, len(statementRows))
+	for i, row := range statementRows {
+		transactions[i] = row.transaction()
+	}
+
+	_, err = results.Exec()
+	if err != nil {
+		return Client{}, nil, err
+	}
+
+	return client, transactions, nil
+}
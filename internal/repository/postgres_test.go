@@ -0,0 +1,436 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/config"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/leader"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/migrations"
+)
+
+// The Postgres tests need databases they may wipe: TEST_DATABASE_URL for
+// one, TEST_DATABASE_SHARD_URLS, comma separated, for the sharded ones.
+// They are skipped when unset.
+
+var testPool = config.Pool{
+	MaxConns:          8,
+	MaxConnLifetime:   time.Hour,
+	MaxConnIdleTime:   time.Minute,
+	HealthCheckPeriod: time.Minute,
+	ConnectTimeout:    5 * time.Second,
+	LockTimeout:       5 * time.Second,
+	QueryExecMode:     "cache_statement",
+}
+
+func testDatabaseURL(t *testing.T) string {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	return url
+}
+
+// openTestDatabase drops everything the migrations created in the database
+// at url and migrates it again.
+func openTestDatabase(t *testing.T, url string) *Failover {
+	t.Helper()
+	ctx := context.Background()
+
+	db, err := NewFailover(ctx, []string{url}, testPool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+
+	_, err = db.Pool().Exec(ctx, "DROP SCHEMA IF EXISTS bank CASCADE; DROP TABLE IF EXISTS public.schema_migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = migrations.Apply(ctx, db.Pool())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func openTestPostgres(t *testing.T, db *Failover, advisoryLocks bool) *Postgres {
+	t.Helper()
+
+	repo, err := NewPostgres(db, "read committed", advisoryLocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.Seed(context.Background(), testClients)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return repo
+}
+
+func TestPostgres(t *testing.T) {
+	url := testDatabaseURL(t)
+
+	testRepository(t, func(t *testing.T) store {
+		return openTestPostgres(t, openTestDatabase(t, url), false)
+	})
+}
+
+func TestPostgresAdvisoryLocks(t *testing.T) {
+	url := testDatabaseURL(t)
+
+	testRepository(t, func(t *testing.T) store {
+		return openTestPostgres(t, openTestDatabase(t, url), true)
+	})
+}
+
+// Databases from the former initdb scripts lack clients_balance_check
+// until migration 0012 adds it.
+func TestPostgresLegacyBalanceCheck(t *testing.T) {
+	db := openTestDatabase(t, testDatabaseURL(t))
+	repo := openTestPostgres(t, db, false)
+	ctx := context.Background()
+
+	for _, sql := range []string{
+		// Reapplied over the constraint, it changes nothing.
+		"DELETE FROM public.schema_migrations WHERE version = '0012_clients_balance_check'",
+		"ALTER TABLE bank.clients DROP CONSTRAINT clients_balance_check",
+		"DELETE FROM public.schema_migrations WHERE version = '0012_clients_balance_check'",
+	} {
+		_, err := db.Pool().Exec(ctx, sql)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = migrations.Apply(ctx, db.Pool())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, _, err := repo.ApplyTransaction(ctx, 2, Transaction{Amount: 501, Type: "d", Description: "debito"})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("got %v, want ErrLimitExceeded", err)
+	}
+}
+
+// The leader's lock must not hold up writes to client 1, nor the client
+// locks the leader election.
+func TestPostgresAdvisoryLocksSkipLeaderLock(t *testing.T) {
+	db := openTestDatabase(t, testDatabaseURL(t))
+	repo := openTestPostgres(t, db, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	elected := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		leader.Run(ctx, db, time.Second, func(ctx context.Context) {
+			close(elected)
+			<-ctx.Done()
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	select {
+	case <-elected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not elected leader")
+	}
+
+	writeCtx, cancelWrite := context.WithTimeout(context.Background(), time.Second)
+	defer cancelWrite()
+
+	_, _, err := repo.ApplyTransaction(writeCtx, 1, Transaction{Amount: 1, Type: "c", Description: "credito"})
+	if err != nil {
+		t.Fatalf("write to client 1 while leading: %v", err)
+	}
+}
+
+// Pages deep in a long ledger, filtered or not, must carry the same
+// balances as the Memory repository's walk over the whole of it.
+func TestPostgresStatementDeepPages(t *testing.T) {
+	repo := openTestPostgres(t, openTestDatabase(t, testDatabaseURL(t)), false)
+	memory := NewMemory(testClients)
+	ctx := context.Background()
+
+	for i := 0; i < 300; i++ {
+		tr := Transaction{Amount: i%7 + 1, Type: "c", Description: "credito"}
+		if i%3 == 0 {
+			tr.Type, tr.Description = "d", "debito"
+		}
+
+		for _, s := range []store{repo, memory} {
+			_, _, err := s.ApplyTransaction(ctx, 1, tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, q := range []StatementQuery{
+		{Limit: 10},
+		{Limit: 10, Offset: 150},
+		{Limit: 10, Type: "d"},
+		{Limit: 10, Type: "d", Offset: 40},
+		{Limit: 10, Type: "c", Offset: 195},
+		{Limit: 10, Offset: 295},
+	} {
+		_, got, err := repo.Statement(ctx, 1, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, want, err := memory.Statement(ctx, 1, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertSameLedger(t, q, got, want)
+
+		// The same page reached through the cursor instead of the offset.
+		if q.Offset == 0 || len(want) == 0 {
+			continue
+		}
+
+		cursor := q
+		cursor.Offset = 0
+		_, before, err := repo.Statement(ctx, 1, StatementQuery{Limit: 1, Offset: q.Offset - 1, Type: q.Type})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cursor.Before = before[0].ID
+
+		_, got, err = repo.Statement(ctx, 1, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertSameLedger(t, cursor, got, want)
+	}
+}
+
+func assertSameLedger(t *testing.T, q StatementQuery, got, want []Transaction) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("%+v: got %d transactions, want %d", q, len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i].Amount != want[i].Amount || got[i].Type != want[i].Type || got[i].BalanceAfter != want[i].BalanceAfter {
+			t.Fatalf("%+v: transaction %d is %+v, want %+v", q, i, got[i], want[i])
+		}
+	}
+}
+
+// Only connections opted in to notifications announce their writes.
+func TestPostgresClientChangeNotifications(t *testing.T) {
+	url := testDatabaseURL(t)
+	db := openTestDatabase(t, url)
+	quiet := openTestPostgres(t, db, false)
+	ctx := context.Background()
+
+	pool := testPool
+	pool.NotifyClientChanges = true
+	notifyingDB, err := NewFailover(ctx, []string{url}, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(notifyingDB.Close)
+	notifying, err := NewPostgres(notifyingDB, "read committed", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pooled, err := db.Pool().Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := pooled.Hijack()
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "LISTEN bank_clients")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wait := func() (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer cancel()
+
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		return n.Payload, nil
+	}
+
+	_, _, err = quiet.ApplyTransaction(ctx, 1, Transaction{Amount: 10, Type: "c", Description: "credito"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := wait()
+	if err == nil {
+		t.Fatalf("got %q from a connection not opted in", payload)
+	}
+
+	_, _, err = notifying.ApplyTransaction(ctx, 1, Transaction{Amount: 10, Type: "c", Description: "credito"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err = wait()
+	if err != nil || payload != "1,20,1000" {
+		t.Fatalf("got %q, %v; want 1,20,1000", payload, err)
+	}
+}
+
+func TestPostgresOutbox(t *testing.T) {
+	repo := openTestPostgres(t, openTestDatabase(t, testDatabaseURL(t)), false)
+	repo.UseOutbox()
+	ctx := context.Background()
+
+	_, tr, err := repo.ApplyTransaction(ctx, 1, Transaction{Amount: 100, Type: "c", Description: "credito"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.UpdateLimit(ctx, 1, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed publish leaves the events pending.
+	_, err = repo.RelayOutbox(ctx, 10, func(context.Context, []Event) error {
+		return errors.New("broker down")
+	})
+	if err == nil {
+		t.Fatal("RelayOutbox succeeded with a failing publish")
+	}
+
+	var events []Event
+	n, err := repo.RelayOutbox(ctx, 10, func(_ context.Context, batch []Event) error {
+		events = append(events, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || events[0].Type != TransactionCreatedEvent || events[1].Type != LimitChangedEvent {
+		t.Fatalf("relayed %d events: %+v", n, events)
+	}
+
+	var created TransactionCreated
+	err = json.Unmarshal(events[0].Payload, &created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != tr.ID || created.Balance != 100 {
+		t.Fatalf("got payload %+v", created)
+	}
+
+	n, err = repo.RelayOutbox(ctx, 10, func(context.Context, []Event) error { return nil })
+	if err != nil || n != 0 {
+		t.Fatalf("relayed %d events again, error %v", n, err)
+	}
+}
+
+func TestSharded(t *testing.T) {
+	urls := strings.Split(os.Getenv("TEST_DATABASE_SHARD_URLS"), ",")
+	if len(urls) < 2 {
+		t.Skip("TEST_DATABASE_SHARD_URLS needs at least two databases")
+	}
+
+	shards := make([]*Postgres, len(urls))
+	for i, url := range urls {
+		shard, err := NewPostgres(openTestDatabase(t, url), "read committed", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards[i] = shard
+	}
+	repo := NewSharded(shards, urls)
+	ctx := context.Background()
+
+	exists, err := repo.SchemaExists(ctx)
+	if err != nil || !exists {
+		t.Fatalf("got %v, %v; want the schema in every shard", exists, err)
+	}
+
+	clients := make([]Client, 20)
+	for i := range clients {
+		clients[i] = Client{ID: i + 1, Limit: 1000}
+	}
+	err = repo.Seed(ctx, clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := repo.ClientIDs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != len(clients) {
+		t.Fatalf("got %d clients", len(ids))
+	}
+
+	// Each write lands only in the client's own shard.
+	var sameShard, otherShard [2]int
+	for _, c := range clients {
+		_, _, err := repo.ApplyTransaction(ctx, c.ID, Transaction{Amount: c.ID, Type: "c", Description: "credito"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, shard := range shards {
+			got, err := shard.FindClient(ctx, c.ID)
+			if shard != repo.shard(c.ID) {
+				if !errors.Is(err, ErrClientNotFound) {
+					t.Fatalf("client %d found outside its shard: %v", c.ID, err)
+				}
+				continue
+			}
+			if err != nil || got.Balance != c.ID {
+				t.Fatalf("client %d has balance %d in its shard, error %v", c.ID, got.Balance, err)
+			}
+		}
+
+		switch {
+		case c.ID == 1:
+		case repo.shard(c.ID) == repo.shard(1):
+			sameShard = [2]int{1, c.ID}
+		default:
+			otherShard = [2]int{1, c.ID}
+		}
+	}
+
+	if sameShard[0] != 0 {
+		_, err = repo.Transfer(ctx, sameShard[0], sameShard[1], Transaction{Amount: 1, Description: "pix"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if otherShard[0] != 0 {
+		_, err = repo.Transfer(ctx, otherShard[0], otherShard[1], Transaction{Amount: 1, Description: "pix"})
+		if !errors.Is(err, ErrCrossShard) {
+			t.Fatalf("got %v, want ErrCrossShard", err)
+		}
+	}
+}
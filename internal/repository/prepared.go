@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The hot-path queries, kept here so they can be prepared up front.
+
+const findClientSQL = `SELECT "limit", balance FROM bank.clients WHERE id = $1`
+
+const processTransactionSQL = `
+    SELECT new_balance, client_limit, status, transaction_id, transaction_created_at
+    FROM bank.process_transaction($1, $2, $3, $4)
+  `
+
+const statementClientSQL = `
+    SELECT
+      "limit",
+      balance,
+      (SELECT COALESCE(max(id), 0) FROM bank.transactions WHERE client_id = $1) AS last_transaction_id
+    FROM bank.clients
+    WHERE id = $1
+  `
+
+// statementTransactionsSQL selects a statement page, with filter being the
+// extra predicates from statementFilter. Each balance_after is the current
+// balance minus every newer transaction, filtered out or not: those newer
+// than the page are summed straight off the index, and only the rows the
+// page spans go through the window, so the cost follows the page and not
+// the length of the ledger.
+func statementTransactionsSQL(filter string) string {
+	return `
+    WITH page AS (
+      SELECT id, amount, description, "type", created_at
+      FROM bank.transactions
+      WHERE client_id = $1` + filter + `
+      ORDER BY id DESC
+      LIMIT $2
+      OFFSET $3
+    ),
+    newer AS (
+      SELECT COALESCE(SUM(CASE WHEN "type" = 'd' THEN -amount ELSE amount END), 0) AS total
+      FROM bank.transactions
+      WHERE client_id = $1 AND id > (SELECT max(id) FROM page)
+    ),
+    span AS (
+      SELECT
+        id,
+        COALESCE(SUM(CASE WHEN "type" = 'd' THEN -amount ELSE amount END) OVER (
+          ORDER BY id DESC ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+        ), 0) AS newer
+      FROM bank.transactions
+      WHERE client_id = $1 AND id BETWEEN (SELECT min(id) FROM page) AND (SELECT max(id) FROM page)
+    )
+    SELECT
+      id,
+      amount,
+      description,
+      "type",
+      created_at,
+      (SELECT balance FROM bank.clients WHERE id = $1) - (SELECT total FROM newer) - span.newer AS balance_after
+    FROM page
+    JOIN span USING (id)
+    ORDER BY id DESC
+  `
+}
+
+// hotQueries are prepared on every new connection.
+var hotQueries = []string{
+	findClientSQL,
+	processTransactionSQL,
+	statementClientSQL,
+	statementTransactionsSQL(""),
+}
+
+// prepareHotQueries is the pool's AfterConnect hook. Each query is prepared
+// under its own text as the name, which pgx looks up on every query, so
+// call sites stay unchanged. Failures are only logged: before migrations
+// run the tables may not exist yet, and pgx still prepares on first use.
+func prepareHotQueries(ctx context.Context, conn *pgx.Conn) error {
+	for _, sql := range hotQueries {
+		_, err := conn.Prepare(ctx, sql, sql)
+		if err != nil {
+			slog.Debug("Unable to prepare query", "error", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrClientNotFound = errors.New("client not found")
+	ErrLimitExceeded  = errors.New("limit exceeded")
+	ErrClientExists   = errors.New("client already exists")
+	// ErrBusy means the database timed out waiting on a lock; the request
+	// can be retried.
+	ErrBusy = errors.New("database busy")
+	// ErrCrossShard means a transfer's clients live in different databases.
+	ErrCrossShard = errors.New("clients are in different shards")
+
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrAlreadyReversed     = errors.New("transaction already reversed")
+	ErrNotReversible       = errors.New("transaction is a reversal")
+)
+
+type Client struct {
+	ID      int
+	Limit   int
+	Balance int
+	// LastTransactionID is the id of the client's newest transaction, or 0.
+	// Only Statement fills it.
+	LastTransactionID int64
+}
+
+type Transaction struct {
+	ID          int64
+	Amount      int
+	Type        string
+	Description string
+	CreatedAt   time.Time
+	// LinkedID is the counterpart ledger row of a transfer, or 0.
+	LinkedID int64
+	// ReversalOf is the transaction this one reverses, or 0.
+	ReversalOf int64
+	// BalanceAfter is the client's balance right after this transaction. Only
+	// Statement fills it.
+	BalanceAfter int
+	// IdempotencyKey, when set, makes ApplyTransaction return the stored
+	// result instead of applying t again.
+	IdempotencyKey string
+}
+
+// StatementQuery selects which transactions a statement shows, newest
+// first.
+type StatementQuery struct {
+	Limit  int
+	Offset int
+	// From and To bound created_at, inclusively. Zero values leave that
+	// side open.
+	From time.Time
+	To   time.Time
+	// Type keeps only credits ("c") or debits ("d") when set.
+	Type string
+	// Before, when set, keeps only transactions with a smaller id, for
+	// keyset pagination.
+	Before int64
+}
+
+func (q StatementQuery) matches(t Transaction) bool {
+	if !q.From.IsZero() && t.CreatedAt.Before(q.From) {
+		return false
+	}
+
+	if !q.To.IsZero() && t.CreatedAt.After(q.To) {
+		return false
+	}
+
+	if q.Type != "" && t.Type != q.Type {
+		return false
+	}
+
+	if q.Before != 0 && t.ID >= q.Before {
+		return false
+	}
+
+	return true
+}
+
+// BatchError reports which item of a batch failed.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ReversalDescription is the descricao of compensating transactions.
+const ReversalDescription = "estorno"
+
+// DefaultClients are the clients every rinha deployment starts with.
+var DefaultClients = []Client{
+	{ID: 1, Limit: 100000},
+	{ID: 2, Limit: 80000},
+	{ID: 3, Limit: 1000000},
+	{ID: 4, Limit: 10000000},
+	{ID: 5, Limit: 500000},
+}
+
+type ClientRepository interface {
+	// ClientIDs lists the ids of every existing client.
+	ClientIDs(ctx context.Context) ([]int, error)
+	// Clients lists every existing client with its balance and limit.
+	Clients(ctx context.Context) ([]Client, error)
+	FindClient(ctx context.Context, id int) (Client, error)
+	// CreateClient fails with ErrClientExists when the id is taken.
+	CreateClient(ctx context.Context, c Client) error
+	// UpdateLimit fails with ErrLimitExceeded when the current balance
+	// would fall below the new limit.
+	UpdateLimit(ctx context.Context, id int, limit int) (Client, error)
+	// DeleteClient removes the client along with its transactions.
+	DeleteClient(ctx context.Context, id int) error
+	// ApplyTransaction atomically updates the client's balance and records t,
+	// returning the updated client and the stored transaction.
+	ApplyTransaction(ctx context.Context, clientID int, t Transaction) (Client, Transaction, error)
+	// ApplyTransactions applies all of ts or none of them, returning the
+	// client as it stood after each one. Failures are *BatchError.
+	ApplyTransactions(ctx context.Context, clientID int, ts []Transaction) ([]Client, error)
+	// Transfer atomically moves t.Amount from one client to another,
+	// honoring the sender's limit, and returns the updated sender.
+	Transfer(ctx context.Context, fromID, toID int, t Transaction) (Client, error)
+	// Reverse records a compensating transaction for transactionID and
+	// restores the balance, returning the updated client.
+	Reverse(ctx context.Context, clientID int, transactionID int64) (Client, error)
+}
+
+type TransactionRepository interface {
+	// Statement returns the client together with the transactions selected
+	// by q, newest first. A client without transactions comes back with an
+	// empty, non-nil slice; ErrClientNotFound only means the client doesn't
+	// exist.
+	Statement(ctx context.Context, clientID int, q StatementQuery) (Client, []Transaction, error)
+	// FindTransaction fails with ErrTransactionNotFound when the transaction
+	// doesn't exist or belongs to another client.
+	FindTransaction(ctx context.Context, clientID int, id int64) (Transaction, error)
+	// EachTransaction calls fn for every transaction of the client, oldest
+	// first, without loading them all in memory. It stops at fn's first
+	// error and returns it.
+	EachTransaction(ctx context.Context, clientID int, fn func(Transaction) error) error
+}
@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// store is what every backend implements; the tests below run against
+// each of them.
+type store interface {
+	ClientRepository
+	TransactionRepository
+}
+
+// testClients are seeded into every backend under test.
+var testClients = []Client{
+	{ID: 1, Limit: 1000},
+	{ID: 2, Limit: 500},
+}
+
+// testRepository runs the behavior shared by all backends against the
+// stores open returns, each seeded with testClients.
+func testRepository(t *testing.T, open func(t *testing.T) store) {
+	t.Run("ApplyTransaction", func(t *testing.T) {
+		repo := open(t)
+		ctx := context.Background()
+
+		c, tr, err := repo.ApplyTransaction(ctx, 1, Transaction{Amount: 300, Type: "c", Description: "credito"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Balance != 300 || tr.ID == 0 {
+			t.Fatalf("got balance %d, id %d; want 300 and an id", c.Balance, tr.ID)
+		}
+
+		c, _, err = repo.ApplyTransaction(ctx, 1, Transaction{Amount: 1300, Type: "d", Description: "debito"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Balance != -1000 {
+			t.Fatalf("got balance %d, want -1000", c.Balance)
+		}
+
+		_, _, err = repo.ApplyTransaction(ctx, 1, Transaction{Amount: 1, Type: "d", Description: "debito"})
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("got %v, want ErrLimitExceeded", err)
+		}
+
+		_, _, err = repo.ApplyTransaction(ctx, 99, Transaction{Amount: 1, Type: "c", Description: "credito"})
+		if !errors.Is(err, ErrClientNotFound) {
+			t.Fatalf("got %v, want ErrClientNotFound", err)
+		}
+
+		assertBalance(t, repo, 1, -1000)
+	})
+
+	// Debits racing for the same client never take it past its limit.
+	t.Run("ConcurrentDebits", func(t *testing.T) {
+		repo := open(t)
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		var refused atomic.Int32
+		for i := 0; i < 150; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for {
+					_, _, err := repo.ApplyTransaction(ctx, 1, Transaction{Amount: 10, Type: "d", Description: "debito"})
+					switch {
+					case errors.Is(err, ErrBusy):
+						continue
+					case errors.Is(err, ErrLimitExceeded):
+						refused.Add(1)
+					case err != nil:
+						t.Error(err)
+					}
+					return
+				}
+			}()
+		}
+		wg.Wait()
+
+		if refused.Load() != 50 {
+			t.Fatalf("got %d debits refused, want 50", refused.Load())
+		}
+		assertBalance(t, repo, 1, -1000)
+	})
+
+	t.Run("IdempotencyKey", func(t *testing.T) {
+		repo := open(t)
+		ctx := context.Background()
+
+		t1 := Transaction{Amount: 100, Type: "c", Description: "credito", IdempotencyKey: "k1"}
+		c1, tr1, err := repo.ApplyTransaction(ctx, 1, t1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = repo.ApplyTransaction(ctx, 1, Transaction{Amount: 50, Type: "c", Description: "credito"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c2, tr2, err := repo.ApplyTransaction(ctx, 1, t1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tr2.ID != tr1.ID || c2.Balance != c1.Balance {
+			t.Fatalf("replay got id %d, balance %d; want %d, %d", tr2.ID, c2.Balance, tr1.ID, c1.Balance)
+		}
+
+		assertBalance(t, repo, 1, 150)
+	})
+
+	t.Run("ApplyTransactions", func(t *testing.T) {
+		repo := open(t)
+		ctx := context.Background()
+
+		results, err := repo.ApplyTransactions(ctx, 2, []Transaction{
+			{Amount: 100, Type: "c", Description: "a"},
+			{Amount: 200, Type: "d", Description: "b"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].Balance != 100 || results[1].Balance != -100 {
+			t.Fatalf("got %+v", results)
+		}
+
+		_, err = repo.ApplyTransactions(ctx, 2, []Transaction{
+			{Amount: 100, Type: "d", Description: "a"},
+			{Amount: 500, Type: "d", Description: "b"},
+		})
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("got %v, want a limit exceeded BatchError at 1", err)
+		}
+
+		assertBalance(t, repo, 2, -100)
+	})
+
+	t.Run("Transfer", func(t *testing.T) {
+		repo := open(t)
+		ctx := context.Background()
+
+		from, err := repo.Transfer(ctx, 1, 2, Transaction{Amount: 400, Description: "pix"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if from.Balance != -400 {
+			t.Fatalf("got sender balance %d, want -400", from.Balance)
+		}
+
+		_, err = repo.Transfer(ctx, 1, 2, Transaction{Amount: 601, Description: "pix"})
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("got %v, want ErrLimitExceeded", err)
+		}
+
+		assertBalance(t, repo, 1, -400)
+		assertBalance(t, repo, 2, 400)
+	})
+
+	t.Run("Reverse", func(t *testing.T) {
+		repo := open(t)
+		ctx := context.Background()
+
+		_, tr, err := repo.ApplyTransaction(ctx, 1, Transaction{Amount: 250, Type: "d", Description: "debito"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := repo.Reverse(ctx, 1, tr.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Balance != 0 {
+			t.Fatalf("got balance %d, want 0", c.Balance)
+		}
+
+		_, err = repo.Reverse(ctx, 1, tr.ID)
+		if !errors.Is(err, ErrAlreadyReversed) {
+			t.Fatalf("got %v, want ErrAlreadyReversed", err)
+		}
+
+		_, reversals, err := repo.Statement(ctx, 1, StatementQuery{Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = repo.Reverse(ctx, 1, reversals[0].ID)
+		if !errors.Is(err, ErrNotReversible) {
+			t.Fatalf("got %v, want ErrNotReversible", err)
+		}
+
+		_, err = repo.Reverse(ctx, 2, tr.ID)
+		if !errors.Is(err, ErrTransactionNotFound) {
+			t.Fatalf("got %v, want ErrTransactionNotFound", err)
+		}
+	})
+
+	t.Run("UpdateLimit", func(t *testing.T) {
+		repo := open(t)
+		ctx := context.Background()
+
+		_, _, err := repo.ApplyTransaction(ctx, 1, Transaction{Amount: 800, Type: "d", Description: "debito"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = repo.UpdateLimit(ctx, 1, 799)
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("got %v, want ErrLimitExceeded", err)
+		}
+
+		c, err := repo.UpdateLimit(ctx, 1, 800)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Limit != 800 {
+			t.Fatalf("got limit %d, want 800", c.Limit)
+		}
+	})
+
+	t.Run("Statement", func(t *testing.T) {
+		repo := open(t)
+		ctx := context.Background()
+
+		c, ts, err := repo.Statement(ctx, 2, StatementQuery{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ts == nil || len(ts) != 0 || c.LastTransactionID != 0 {
+			t.Fatalf("got %v, last id %d; want an empty page", ts, c.LastTransactionID)
+		}
+
+		// Balances after each: 100, 70, 270, 260, 360.
+		for _, tr := range []Transaction{
+			{Amount: 100, Type: "c", Description: "a"},
+			{Amount: 30, Type: "d", Description: "b"},
+			{Amount: 200, Type: "c", Description: "c"},
+			{Amount: 10, Type: "d", Description: "d"},
+			{Amount: 100, Type: "c", Description: "e"},
+		} {
+			_, _, err := repo.ApplyTransaction(ctx, 1, tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		c, ts, err = repo.Statement(ctx, 1, StatementQuery{Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Balance != 360 || c.LastTransactionID != ts[0].ID {
+			t.Fatalf("got balance %d, last id %d; want 360, %d", c.Balance, c.LastTransactionID, ts[0].ID)
+		}
+		assertPage(t, ts, "e", 360, "d", 260)
+
+		_, ts, err = repo.Statement(ctx, 1, StatementQuery{Limit: 2, Before: ts[1].ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPage(t, ts, "c", 270, "b", 70)
+
+		_, ts, err = repo.Statement(ctx, 1, StatementQuery{Limit: 10, Type: "d"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPage(t, ts, "d", 260, "b", 70)
+
+		_, ts, err = repo.Statement(ctx, 1, StatementQuery{Limit: 2, Offset: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertPage(t, ts, "b", 70, "a", 100)
+
+		_, _, err = repo.Statement(ctx, 99, StatementQuery{Limit: 10})
+		if !errors.Is(err, ErrClientNotFound) {
+			t.Fatalf("got %v, want ErrClientNotFound", err)
+		}
+	})
+}
+
+func assertBalance(t *testing.T, repo store, id, want int) {
+	t.Helper()
+
+	c, err := repo.FindClient(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Balance != want {
+		t.Fatalf("client %d has balance %d, want %d", id, c.Balance, want)
+	}
+}
+
+// assertPage checks ts holds the transactions with the given descriptions
+// and balances after, given in pairs.
+func assertPage(t *testing.T, ts []Transaction, want ...any) {
+	t.Helper()
+
+	if len(ts) != len(want)/2 {
+		t.Fatalf("got %d transactions, want %d", len(ts), len(want)/2)
+	}
+
+	for i, tr := range ts {
+		if tr.Description != want[2*i] || tr.BalanceAfter != want[2*i+1] {
+			t.Fatalf("transaction %d is %q with balance %d, want %q with %d", i, tr.Description, tr.BalanceAfter, want[2*i], want[2*i+1])
+		}
+	}
+}
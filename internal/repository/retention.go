@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Purger deletes stored data past its retention.
+type Purger interface {
+	// PurgeIdempotencyKeys deletes the idempotency keys created before
+	// before, returning how many were deleted.
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
+}
+
+func (p *Postgres) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := p.pool().Exec(ctx, `DELETE FROM bank.idempotency_keys WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (s *Sharded) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	for _, shard := range s.shards {
+		n, err := shard.PurgeIdempotencyKeys(ctx, before)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+	}
+
+	return purged, nil
+}
+
+// PurgeEvery deletes the idempotency keys older than ttl every interval
+// until ctx is done. A retry of a request whose key was purged is applied
+// again, so ttl must outlast how long clients keep retrying.
+func PurgeEvery(ctx context.Context, p Purger, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := p.PurgeIdempotencyKeys(ctx, time.Now().Add(-ttl))
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Error("Unable to purge idempotency keys", "error", err)
+				continue
+			}
+
+			if n > 0 {
+				slog.Info("Purged idempotency keys", "count", n)
+			}
+		}
+	}
+}
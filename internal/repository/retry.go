@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	maxRetries     = 5
+	baseRetryDelay = 5 * time.Millisecond
+	maxRetryDelay  = 100 * time.Millisecond
+)
+
+// withRetry runs fn again with jittered exponential backoff while it keeps
+// failing with serialization, deadlock or transient connection errors, up
+// to maxRetries attempts.
+func withRetry(ctx context.Context, fn func() error) error {
+	return retry(ctx, isRetryable, fn)
+}
+
+// retry is withRetry for errors judged retryable by retryable.
+func retry(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	delay := baseRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == maxRetries || !retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(jitter(delay)):
+		}
+
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// jitter picks a delay between half and all of d, so clients failing
+// together do not retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		// Network failures, e.g. a reset connection, when pgx knows the
+		// query never reached the server.
+		return pgconn.SafeToRetry(err)
+	}
+
+	switch pgErr.Code {
+	case "40001", "40P01":
+		// serialization_failure, deadlock_detected
+		return true
+	case "53300", "57P01", "57P02", "57P03":
+		// too_many_connections, admin_shutdown, crash_shutdown,
+		// cannot_connect_now
+		return true
+	}
+
+	// connection_exception and its subclasses
+	return strings.HasPrefix(pgErr.Code, "08")
+}
+
+// isTimeout reports whether Postgres gave up waiting on a lock or cancelled
+// a statement, e.g. on lock_timeout or statement_timeout.
+func isTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "55P03" || pgErr.Code == "57014")
+}
+
+func isCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23514"
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"time"
+)
+
+// The row structs map query columns by name with pgx.RowToStructByName, so
+// a query and its scan cannot drift apart: a column without a field, or a
+// field without a column, is an error instead of a shifted value.
+
+type clientRow struct {
+	ID      int `db:"id"`
+	Limit   int `db:"limit"`
+	Balance int `db:"balance"`
+}
+
+func (r clientRow) client() Client {
+	return Client{ID: r.ID, Limit: r.Limit, Balance: r.Balance}
+}
+
+// statementClientRow is the client block of a statement.
+type statementClientRow struct {
+	Limit             int   `db:"limit"`
+	Balance           int   `db:"balance"`
+	LastTransactionID int64 `db:"last_transaction_id"`
+}
+
+type transactionRow struct {
+	ID          int64     `db:"id"`
+	Amount      int       `db:"amount"`
+	Description string    `db:"description"`
+	Type        string    `db:"type"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+func (r transactionRow) transaction() Transaction {
+	return Transaction{
+		ID:          r.ID,
+		Amount:      r.Amount,
+		Description: r.Description,
+		Type:        r.Type,
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+// statementRow is a transaction along with the balance it left.
+type statementRow struct {
+	transactionRow
+	BalanceAfter int `db:"balance_after"`
+}
+
+func (r statementRow) transaction() Transaction {
+	tr := r.transactionRow.transaction()
+	tr.BalanceAfter = r.BalanceAfter
+
+	return tr
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type seedClient struct {
+	ID      int `json:"id"`
+	Limit   int `json:"limite"`
+	Balance int `json:"saldo"`
+}
+
+// ReadClients loads a seed set from a JSON file shaped like
+// [{"id": 1, "limite": 100000, "saldo": 0}].
+func ReadClients(path string) ([]Client, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read seed file: %w", err)
+	}
+
+	var seeds []seedClient
+	err = json.Unmarshal(content, &seeds)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse seed file %s: %w", path, err)
+	}
+
+	clients := make([]Client, 0, len(seeds))
+	for _, s := range seeds {
+		if s.Limit < 0 || s.Balance < -s.Limit {
+			return nil, fmt.Errorf("Invalid seed for client %d: saldo %d exceeds limite %d", s.ID, s.Balance, s.Limit)
+		}
+
+		clients = append(clients, Client{ID: s.ID, Limit: s.Limit, Balance: s.Balance})
+	}
+
+	return clients, nil
+}
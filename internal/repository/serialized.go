@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+)
+
+// Serialized queues each client's writes on one of a fixed set of worker
+// goroutines, picked by client id, so they reach storage one at a time
+// instead of contending for the client's row lock. Reads and transfers,
+// which span two clients, go straight to the wrapped repository.
+type Serialized struct {
+	ClientRepository
+	shards []chan func()
+}
+
+// NewSerialized starts shards workers in front of repo. They live as long as
+// the process.
+func NewSerialized(repo ClientRepository, shards int) *Serialized {
+	s := &Serialized{ClientRepository: repo, shards: make([]chan func(), shards)}
+
+	for i := range s.shards {
+		jobs := make(chan func(), 64)
+		s.shards[i] = jobs
+
+		go func() {
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
+
+	return s
+}
+
+// do runs fn on the client's worker once the writes queued before it are
+// done. fn is skipped when ctx is done before its turn comes.
+func (s *Serialized) do(ctx context.Context, clientID int, fn func()) error {
+	done := make(chan struct{})
+	ran := false
+
+	job := func() {
+		defer close(done)
+
+		if ctx.Err() == nil {
+			ran = true
+			fn()
+		}
+	}
+
+	select {
+	case s.shards[uint(clientID)%uint(len(s.shards))] <- job:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	<-done
+	if !ran {
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+func (s *Serialized) ApplyTransaction(ctx context.Context, clientID int, t Transaction) (client Client, tr Transaction, err error) {
+	qerr := s.do(ctx, clientID, func() {
+		client, tr, err = s.ClientRepository.ApplyTransaction(ctx, clientID, t)
+	})
+	if qerr != nil {
+		return Client{}, Transaction{}, qerr
+	}
+
+	return client, tr, err
+}
+
+func (s *Serialized) ApplyTransactions(ctx context.Context, clientID int, ts []Transaction) (clients []Client, err error) {
+	qerr := s.do(ctx, clientID, func() {
+		clients, err = s.ClientRepository.ApplyTransactions(ctx, clientID, ts)
+	})
+	if qerr != nil {
+		return nil, qerr
+	}
+
+	return clients, err
+}
+
+func (s *Serialized) Reverse(ctx context.Context, clientID int, transactionID int64) (client Client, err error) {
+	qerr := s.do(ctx, clientID, func() {
+		client, err = s.ClientRepository.Reverse(ctx, clientID, transactionID)
+	})
+	if qerr != nil {
+		return Client{}, qerr
+	}
+
+	return client, err
+}
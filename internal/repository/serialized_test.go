@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// serializedStore serves the writes through Serialized and the reads
+// straight from memory.
+type serializedStore struct {
+	*Serialized
+	TransactionRepository
+}
+
+func TestSerialized(t *testing.T) {
+	testRepository(t, func(t *testing.T) store {
+		memory := NewMemory(testClients)
+		return serializedStore{NewSerialized(memory, 2), memory}
+	})
+}
+
+func TestSerializedConcurrentWrites(t *testing.T) {
+	memory := NewMemory(testClients)
+	repo := NewSerialized(memory, 2)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	refused := 0
+	for i := 0; i < 150; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := repo.ApplyTransaction(ctx, 1, Transaction{Amount: 10, Type: "d", Description: "debito"})
+			if errors.Is(err, ErrLimitExceeded) {
+				mu.Lock()
+				refused++
+				mu.Unlock()
+			} else if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if refused != 50 {
+		t.Fatalf("got %d refused, want 50", refused)
+	}
+	assertBalance(t, memory, 1, -1000)
+}
+
+func TestSerializedCanceled(t *testing.T) {
+	memory := NewMemory(testClients)
+	repo := NewSerialized(memory, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := repo.ApplyTransaction(ctx, 1, Transaction{Amount: 10, Type: "c", Description: "credito"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	assertBalance(t, memory, 1, 0)
+}
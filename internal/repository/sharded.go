@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/hashring"
+)
+
+// Sharded spreads clients over several Postgres databases. Each client
+// lives entirely in the shard its id hashes to, so every operation on one
+// client runs on one database.
+type Sharded struct {
+	shards []*Postgres
+	ring   *hashring.Ring
+}
+
+// NewSharded places shards on a hash ring by name, which should identify
+// the database, e.g. its host and name, so reordering the list or changing
+// credentials doesn't move clients.
+func NewSharded(shards []*Postgres, names []string) *Sharded {
+	return &Sharded{shards: shards, ring: hashring.New(names)}
+}
+
+// shard returns the database the client belongs to.
+func (s *Sharded) shard(clientID int) *Postgres {
+	return s.shards[s.ring.Node(strconv.Itoa(clientID))]
+}
+
+// Seed inserts each client in its own shard, leaving existing ones
+// untouched.
+func (s *Sharded) Seed(ctx context.Context, clients []Client) error {
+	for _, c := range clients {
+		err := s.shard(c.ID).Seed(ctx, []Client{c})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SchemaExists reports whether every shard has the bank schema.
+func (s *Sharded) SchemaExists(ctx context.Context) (bool, error) {
+	for _, shard := range s.shards {
+		exists, err := shard.SchemaExists(ctx)
+		if err != nil || !exists {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (s *Sharded) ClientIDs(ctx context.Context) ([]int, error) {
+	var ids []int
+	for _, shard := range s.shards {
+		shardIDs, err := shard.ClientIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, shardIDs...)
+	}
+
+	return ids, nil
+}
+
+func (s *Sharded) Clients(ctx context.Context) ([]Client, error) {
+	var clients []Client
+	for _, shard := range s.shards {
+		shardClients, err := shard.Clients(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, shardClients...)
+	}
+
+	return clients, nil
+}
+
+func (s *Sharded) FindClient(ctx context.Context, id int) (Client, error) {
+	return s.shard(id).FindClient(ctx, id)
+}
+
+func (s *Sharded) CreateClient(ctx context.Context, c Client) error {
+	return s.shard(c.ID).CreateClient(ctx, c)
+}
+
+func (s *Sharded) UpdateLimit(ctx context.Context, id int, limit int) (Client, error) {
+	return s.shard(id).UpdateLimit(ctx, id, limit)
+}
+
+func (s *Sharded) DeleteClient(ctx context.Context, id int) error {
+	return s.shard(id).DeleteClient(ctx, id)
+}
+
+func (s *Sharded) ApplyTransaction(ctx context.Context, clientID int, t Transaction) (Client, Transaction, error) {
+	return s.shard(clientID).ApplyTransaction(ctx, clientID, t)
+}
+
+func (s *Sharded) ApplyTransactions(ctx context.Context, clientID int, ts []Transaction) ([]Client, error) {
+	return s.shard(clientID).ApplyTransactions(ctx, clientID, ts)
+}
+
+// Transfer fails with ErrCrossShard when the clients live in different
+// shards, since no single transaction could move the money atomically.
+func (s *Sharded) Transfer(ctx context.Context, fromID, toID int, t Transaction) (Client, error) {
+	from, to := s.shard(fromID), s.shard(toID)
+	if from != to {
+		// Still report unknown clients as such.
+		for _, id := range []int{fromID, toID} {
+			_, err := s.FindClient(ctx, id)
+			if err != nil {
+				return Client{}, err
+			}
+		}
+
+		return Client{}, ErrCrossShard
+	}
+
+	return from.Transfer(ctx, fromID, toID, t)
+}
+
+func (s *Sharded) Reverse(ctx context.Context, clientID int, transactionID int64) (Client, error) {
+	return s.shard(clientID).Reverse(ctx, clientID, transactionID)
+}
+
+func (s *Sharded) Statement(ctx context.Context, clientID int, q StatementQuery) (Client, []Transaction, error) {
+	return s.shard(clientID).Statement(ctx, clientID, q)
+}
+
+func (s *Sharded) FindTransaction(ctx context.Context, clientID int, id int64) (Transaction, error) {
+	return s.shard(clientID).FindTransaction(ctx, clientID, id)
+}
+
+func (s *Sharded) EachTransaction(ctx context.Context, clientID int, fn func(Transaction) error) error {
+	return s.shard(clientID).EachTransaction(ctx, clientID, fn)
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// memorySnapshot is Memory as written by Snapshot.
+type memorySnapshot struct {
+	LastID  int64            `json:"last_id"`
+	Clients []clientSnapshot `json:"clients"`
+}
+
+type clientSnapshot struct {
+	Client       Client                      `json:"client"`
+	Transactions []Transaction               `json:"transactions"`
+	Idempotent   map[string]idempotentResult `json:"idempotent,omitempty"`
+}
+
+// Snapshot writes every client, its ledger and its idempotency keys to w as
+// JSON, as they stood at one instant.
+func (m *Memory) Snapshot(w io.Writer) error {
+	m.mu.Lock()
+
+	snapshot := memorySnapshot{
+		LastID:  m.lastID.Load(),
+		Clients: make([]clientSnapshot, 0, len(m.clients)),
+	}
+	for _, c := range m.clients {
+		snapshot.Clients = append(snapshot.Clients, clientSnapshot{
+			Client: c.client,
+			// Ledgers are only appended to, so the rows seen now won't
+			// change while they are encoded.
+			Transactions: slices.Clip(c.transactions),
+			Idempotent:   maps.Clone(c.idempotent),
+		})
+	}
+
+	m.mu.Unlock()
+
+	slices.SortFunc(snapshot.Clients, func(a, b clientSnapshot) int {
+		return a.Client.ID - b.Client.ID
+	})
+
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// WriteSnapshot replaces the file at path with a snapshot, through a
+// temporary file so a crash never leaves it half written.
+func (m *Memory) WriteSnapshot(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = m.Snapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// SnapshotEvery writes a snapshot to path every interval until ctx is done.
+func (m *Memory) SnapshotEvery(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := m.WriteSnapshot(path)
+			if err != nil {
+				slog.Error("Unable to write memory snapshot", "path", path, "error", err)
+			}
+		}
+	}
+}
+
+// LoadMemory restores a Memory from the snapshot at path. The error wraps
+// fs.ErrNotExist when there is none yet.
+func LoadMemory(path string) (*Memory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshot memorySnapshot
+	err = json.NewDecoder(f).Decode(&snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid memory snapshot %s: %w", path, err)
+	}
+
+	m := NewMemory(nil)
+	m.lastID.Store(snapshot.LastID)
+	for _, c := range snapshot.Clients {
+		m.clients[c.Client.ID] = &memoryClient{
+			client:       c.Client,
+			transactions: c.Transactions,
+			idempotent:   c.Idempotent,
+		}
+	}
+
+	return m, nil
+}
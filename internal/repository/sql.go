@@ -0,0 +1,635 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/config"
+)
+
+// SQL keeps clients and transactions in a database reached through
+// database/sql, for deployments that can't run Postgres. The limit and
+// reversal rules the Postgres schema enforces with constraints and functions
+// are checked here, with the client row locked.
+type SQL struct {
+	db      *sql.DB
+	dialect dialect
+	queries sqlQueries
+}
+
+// NewSQL connects to the database of the given storage, e.g. mysql,
+// retrying until cfg.ConnectRetryWindow elapses since the database often
+// starts after the API.
+func NewSQL(ctx context.Context, storage, databaseURL string, cfg config.Pool) (*SQL, error) {
+	d, ok := dialects[storage]
+	if !ok {
+		return nil, fmt.Errorf("Invalid storage: %s", storage)
+	}
+
+	db, err := d.open(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(int(cfg.MaxConns))
+	db.SetMaxIdleConns(int(cfg.MaxConns))
+	db.SetConnMaxLifetime(cfg.MaxConnLifetime)
+	db.SetConnMaxIdleTime(cfg.MaxConnIdleTime)
+
+	err = retryWithBackoff(ctx, cfg.ConnectRetryWindow, func() error {
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+		defer cancel()
+
+		return db.PingContext(pingCtx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Unable to ping database: %w", err)
+	}
+
+	return &SQL{db: db, dialect: d, queries: d.build()}, nil
+}
+
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQL) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Migrate creates the missing tables, reporting whether the database was
+// empty so it can be seeded.
+func (s *SQL) Migrate(ctx context.Context) (bool, error) {
+	var tables int
+	err := s.db.QueryRowContext(ctx, s.dialect.tableExists, "clients").Scan(&tables)
+	if err != nil {
+		return false, fmt.Errorf("Unable to inspect database: %w", err)
+	}
+
+	for _, ddl := range s.dialect.schema {
+		_, err := s.db.ExecContext(ctx, ddl)
+		if err != nil {
+			return false, fmt.Errorf("Unable to create schema: %w", err)
+		}
+	}
+
+	return tables == 0, nil
+}
+
+// Seed inserts the given clients, leaving existing ones untouched.
+func (s *SQL) Seed(ctx context.Context, clients []Client) error {
+	for _, c := range clients {
+		err := s.CreateClient(ctx, c)
+		if err != nil && !errors.Is(err, ErrClientExists) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQL) ClientIDs(ctx context.Context) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, s.queries.clientIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (s *SQL) Clients(ctx context.Context) ([]Client, error) {
+	rows, err := s.db.QueryContext(ctx, s.queries.clients)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []Client
+	for rows.Next() {
+		var c Client
+		err := rows.Scan(&c.ID, &c.Limit, &c.Balance)
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, c)
+	}
+
+	return clients, rows.Err()
+}
+
+func (s *SQL) FindClient(ctx context.Context, id int) (Client, error) {
+	client := Client{ID: id}
+	err := s.db.QueryRowContext(ctx, s.queries.findClient, id).Scan(&client.Limit, &client.Balance)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Client{}, ErrClientNotFound
+	}
+
+	return client, err
+}
+
+func (s *SQL) CreateClient(ctx context.Context, c Client) error {
+	_, err := s.db.ExecContext(ctx, s.queries.createClient, c.ID, c.Limit, c.Balance)
+
+	if s.dialect.isUniqueViolation(err) {
+		return ErrClientExists
+	}
+
+	return err
+}
+
+func (s *SQL) UpdateLimit(ctx context.Context, id int, limit int) (Client, error) {
+	var client Client
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		client, err = s.lockClient(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if client.Balance < -limit {
+			return ErrLimitExceeded
+		}
+
+		_, err = tx.ExecContext(ctx, s.queries.updateLimit, limit, id)
+		client.Limit = limit
+		return err
+	})
+
+	if err != nil {
+		return Client{}, err
+	}
+
+	return client, nil
+}
+
+func (s *SQL) DeleteClient(ctx context.Context, id int) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, query := range []string{s.queries.deleteKeys, s.queries.deleteLedger} {
+			_, err := tx.ExecContext(ctx, query, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.ExecContext(ctx, s.queries.deleteClient, id)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if deleted == 0 {
+			return ErrClientNotFound
+		}
+
+		return nil
+	})
+}
+
+func (s *SQL) ApplyTransaction(ctx context.Context, clientID int, t Transaction) (Client, Transaction, error) {
+	var client Client
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		client, err = s.lockClient(ctx, tx, clientID)
+		if err != nil {
+			return err
+		}
+
+		if t.IdempotencyKey != "" {
+			err := tx.QueryRowContext(ctx, s.queries.findKey, clientID, t.IdempotencyKey).
+				Scan(&client.Balance, &client.Limit, &t.ID, &t.CreatedAt)
+			if !errors.Is(err, sql.ErrNoRows) {
+				// Replayed, or failed.
+				return err
+			}
+		}
+
+		client.Balance, err = balanceAfter(client, t)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, s.queries.updateBalance, client.Balance, clientID)
+		if err != nil {
+			return err
+		}
+
+		t, err = s.record(ctx, tx, clientID, t)
+		if err != nil || t.IdempotencyKey == "" {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, s.queries.insertKey,
+			clientID,
+			t.IdempotencyKey,
+			client.Balance,
+			client.Limit,
+			t.ID,
+			t.CreatedAt,
+		)
+		return err
+	})
+
+	if err != nil {
+		return Client{}, Transaction{}, err
+	}
+
+	return client, t, nil
+}
+
+func (s *SQL) ApplyTransactions(ctx context.Context, clientID int, ts []Transaction) ([]Client, error) {
+	var results []Client
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		client, err := s.lockClient(ctx, tx, clientID)
+		if err != nil {
+			return err
+		}
+
+		results = make([]Client, len(ts))
+		for i, t := range ts {
+			client.Balance, err = balanceAfter(client, t)
+			if err != nil {
+				return &BatchError{Index: i, Err: err}
+			}
+
+			_, err = s.record(ctx, tx, clientID, t)
+			if err != nil {
+				return err
+			}
+
+			results[i] = client
+		}
+
+		_, err = tx.ExecContext(ctx, s.queries.updateBalance, client.Balance, clientID)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Transfer debits fromID and credits toID by t.Amount in one transaction,
+// writing a ledger row for each side linked to the other. Client rows are
+// locked in id order so opposite transfers can't deadlock.
+func (s *SQL) Transfer(ctx context.Context, fromID, toID int, t Transaction) (Client, error) {
+	var from Client
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, s.queries.lockClients, fromID, toID)
+		if err != nil {
+			return err
+		}
+
+		var locked []Client
+		for rows.Next() {
+			var c Client
+			err := rows.Scan(&c.ID, &c.Limit, &c.Balance)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			locked = append(locked, c)
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(locked) != 2 {
+			return ErrClientNotFound
+		}
+
+		var to Client
+		from, to = locked[0], locked[1]
+		if from.ID != fromID {
+			from, to = to, from
+		}
+
+		from.Balance, err = balanceAfter(from, Transaction{Amount: t.Amount, Type: "d"})
+		if err != nil {
+			return err
+		}
+		to.Balance += t.Amount
+
+		for _, c := range []Client{from, to} {
+			_, err := tx.ExecContext(ctx, s.queries.updateBalance, c.Balance, c.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		debit, credit := t, t
+		debit.Type, credit.Type = "d", "c"
+
+		debit, err = s.record(ctx, tx, fromID, debit)
+		if err != nil {
+			return err
+		}
+
+		credit.LinkedID = debit.ID
+		credit, err = s.record(ctx, tx, toID, credit)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, s.queries.linkTransaction, credit.ID, debit.ID)
+		return err
+	})
+
+	if err != nil {
+		return Client{}, err
+	}
+
+	return from, nil
+}
+
+func (s *SQL) Reverse(ctx context.Context, clientID int, transactionID int64) (Client, error) {
+	var client Client
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		client, err = s.lockClient(ctx, tx, clientID)
+		if err != nil {
+			return err
+		}
+
+		var original Transaction
+		var reversalOf sql.NullInt64
+
+		err = tx.QueryRowContext(ctx, s.queries.findReversible, transactionID, clientID).
+			Scan(&original.Amount, &original.Type, &reversalOf)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrTransactionNotFound
+			}
+
+			return err
+		}
+
+		if reversalOf.Valid {
+			return ErrNotReversible
+		}
+
+		reversal := Transaction{
+			Amount:      original.Amount,
+			Type:        "d",
+			Description: ReversalDescription,
+			ReversalOf:  transactionID,
+		}
+		if original.Type == "d" {
+			reversal.Type = "c"
+		}
+
+		client.Balance, err = balanceAfter(client, reversal)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, s.queries.updateBalance, client.Balance, clientID)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.record(ctx, tx, clientID, reversal)
+		return err
+	})
+
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return Client{}, ErrAlreadyReversed
+		}
+
+		return Client{}, err
+	}
+
+	return client, nil
+}
+
+func (s *SQL) FindTransaction(ctx context.Context, clientID int, id int64) (Transaction, error) {
+	tr := Transaction{ID: id}
+	err := s.db.QueryRowContext(ctx, s.queries.findTransaction, id, clientID).
+		Scan(&tr.Amount, &tr.Description, &tr.Type, &tr.CreatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Transaction{}, ErrTransactionNotFound
+	}
+
+	return tr, err
+}
+
+func (s *SQL) EachTransaction(ctx context.Context, clientID int, fn func(Transaction) error) error {
+	rows, err := s.db.QueryContext(ctx, s.queries.eachTransaction, clientID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tr Transaction
+		err := rows.Scan(&tr.ID, &tr.Amount, &tr.Description, &tr.Type, &tr.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		err = fn(tr)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Statement reads the client and its ledger in one transaction, so the
+// balance matches the ledger it is walked back along, the way Memory does.
+func (s *SQL) Statement(ctx context.Context, clientID int, q StatementQuery) (Client, []Transaction, error) {
+	client := Client{ID: clientID}
+	var transactions []Transaction
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, s.queries.findClient, clientID).Scan(&client.Limit, &client.Balance)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrClientNotFound
+			}
+
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, s.queries.lastTransactionID, clientID).Scan(&client.LastTransactionID)
+		if err != nil {
+			return err
+		}
+
+		transactions, err = s.ledger(ctx, tx, client, q)
+		return err
+	})
+
+	if err != nil {
+		return Client{}, nil, err
+	}
+
+	return client, transactions, nil
+}
+
+// ledger returns the page of client's transactions q selects, newest first,
+// walking back from the current balance before the filters apply so
+// balance_after stays right for filtered pages too.
+func (s *SQL) ledger(ctx context.Context, tx *sql.Tx, client Client, q StatementQuery) ([]Transaction, error) {
+	query, args := s.queries.ledger, []any{client.ID}
+
+	// Without filters the page is known to end within Offset+Limit rows.
+	if q.From.IsZero() && q.To.IsZero() && q.Type == "" && q.Before == 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Offset+q.Limit)
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := make([]Transaction, 0, q.Limit)
+	skipped := 0
+	balance := client.Balance
+
+	for len(transactions) < q.Limit && rows.Next() {
+		var t Transaction
+		err := rows.Scan(&t.ID, &t.Amount, &t.Description, &t.Type, &t.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		t.BalanceAfter = balance
+		if t.Type == "d" {
+			balance += t.Amount
+		} else {
+			balance -= t.Amount
+		}
+
+		if !q.matches(t) {
+			continue
+		}
+
+		if skipped < q.Offset {
+			skipped++
+			continue
+		}
+
+		transactions = append(transactions, t)
+	}
+
+	return transactions, rows.Err()
+}
+
+// lockClient reads the client, locking its row until tx ends.
+func (s *SQL) lockClient(ctx context.Context, tx *sql.Tx, id int) (Client, error) {
+	client := Client{ID: id}
+	err := tx.QueryRowContext(ctx, s.queries.lockClient, id).Scan(&client.Limit, &client.Balance)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Client{}, ErrClientNotFound
+	}
+
+	return client, err
+}
+
+// record inserts t into the client's ledger, returning it with its id and
+// creation time.
+func (s *SQL) record(ctx context.Context, tx *sql.Tx, clientID int, t Transaction) (Transaction, error) {
+	t.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	result, err := tx.ExecContext(ctx, s.queries.insertTransaction,
+		clientID,
+		t.Amount,
+		t.Description,
+		t.Type,
+		t.CreatedAt,
+		nullID(t.LinkedID),
+		nullID(t.ReversalOf),
+	)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	t.ID, err = result.LastInsertId()
+	return t, err
+}
+
+// inTx runs fn in a transaction, running it again on deadlocks. Lock wait
+// timeouts are reported as ErrBusy.
+func (s *SQL) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	err := retry(ctx, s.dialect.isRetryable, func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		err = fn(tx)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	if s.dialect.isTimeout(err) {
+		return fmt.Errorf("%w: %w", ErrBusy, err)
+	}
+
+	return err
+}
+
+// balanceAfter returns the client's balance once t is applied, or
+// ErrLimitExceeded when it would fall below the limit.
+func balanceAfter(c Client, t Transaction) (int, error) {
+	balance := c.Balance + t.Amount
+	if t.Type == "d" {
+		balance = c.Balance - t.Amount
+	}
+
+	if balance < -c.Limit {
+		return 0, ErrLimitExceeded
+	}
+
+	return balance, nil
+}
+
+// nullID stores a zero id as NULL.
+func nullID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
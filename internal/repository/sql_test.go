@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/config"
+)
+
+func TestSQLite(t *testing.T) {
+	testRepository(t, func(t *testing.T) store {
+		ctx := context.Background()
+
+		repo, err := NewSQL(ctx, "sqlite", filepath.Join(t.TempDir(), "rinha.db"), config.Pool{
+			MaxConns:       4,
+			ConnectTimeout: time.Second,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+
+		_, err = repo.Migrate(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = repo.Seed(ctx, testClients)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repo
+	})
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteParams are added to every SQLite database URL. Transactions take
+// the write lock up front, so two of them can't both read and then fail
+// to upgrade; the busy timeout makes the second one wait instead.
+const sqliteParams = "_txlock=immediate" +
+	"&_time_format=sqlite" +
+	"&_pragma=busy_timeout(5000)" +
+	"&_pragma=journal_mode(WAL)" +
+	"&_pragma=foreign_keys(1)"
+
+var sqliteDialect = dialect{
+	open:        openSQLite,
+	quote:       `"`,
+	tableExists: "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?",
+	schema: []string{
+		`CREATE TABLE IF NOT EXISTS clients (
+			id INTEGER NOT NULL PRIMARY KEY,
+			"limit" INTEGER NOT NULL,
+			balance INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS transactions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			client_id INTEGER NOT NULL REFERENCES clients (id),
+			amount INTEGER NOT NULL DEFAULT 0,
+			description TEXT NULL,
+			"type" TEXT NULL,
+			created_at DATETIME NULL,
+			linked_id INTEGER NULL REFERENCES transactions (id) ON DELETE SET NULL,
+			reversal_of INTEGER NULL UNIQUE REFERENCES transactions (id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS transactions_client_id_idx ON transactions (client_id, id)`,
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			client_id INTEGER NOT NULL REFERENCES clients (id) ON DELETE CASCADE,
+			"key" TEXT NOT NULL,
+			balance INTEGER NOT NULL,
+			"limit" INTEGER NOT NULL,
+			transaction_id INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (client_id, "key")
+		)`,
+	},
+	isUniqueViolation: func(err error) bool {
+		code := sqliteCode(err)
+		return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	},
+	isRetryable: func(err error) bool {
+		// Writers are serialized by _txlock, so nothing deadlocks.
+		return false
+	},
+	isTimeout: func(err error) bool {
+		return sqliteCode(err)&0xff == sqlite3.SQLITE_BUSY
+	},
+}
+
+// sqliteCode returns the extended result code of err, or 0.
+func sqliteCode(err error) int {
+	var liteErr *sqlite.Error
+	if !errors.As(err, &liteErr) {
+		return 0
+	}
+
+	return liteErr.Code()
+}
+
+// openSQLite takes a file path or a file: URI, creating the database when
+// it doesn't exist.
+func openSQLite(databaseURL string) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(databaseURL, "?") {
+		separator = "&"
+	}
+
+	return sql.Open("sqlite", databaseURL+separator+sqliteParams)
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SlowQueryTracer logs statements taking at least threshold. Arguments are
+// left out since they carry client data.
+func SlowQueryTracer(threshold time.Duration) pgx.QueryTracer {
+	return slowQueryTracer{threshold: threshold}
+}
+
+type slowQueryTracer struct {
+	threshold time.Duration
+}
+
+type slowQueryKey struct{}
+
+type slowQueryStart struct {
+	sql   string
+	start time.Time
+}
+
+func (t slowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryKey{}, slowQueryStart{sql: data.SQL, start: time.Now()})
+}
+
+func (t slowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	q, ok := ctx.Value(slowQueryKey{}).(slowQueryStart)
+	if !ok {
+		return
+	}
+
+	elapsed := time.Since(q.start)
+	if elapsed < t.threshold {
+		return
+	}
+
+	slog.WarnContext(ctx, "Slow query",
+		"duration", elapsed,
+		"sql", strings.Join(strings.Fields(q.sql), " "),
+		"error", data.Err,
+	)
+}
+
+// queryTracers fans query events out to several tracers, since pgx takes a
+// single one.
+type queryTracers []pgx.QueryTracer
+
+func (ts queryTracers) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	for _, t := range ts {
+		ctx = t.TraceQueryStart(ctx, conn, data)
+	}
+
+	return ctx
+}
+
+func (ts queryTracers) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	for _, t := range ts {
+		t.TraceQueryEnd(ctx, conn, data)
+	}
+}
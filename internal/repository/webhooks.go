@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID int64
+	// ClientID is the client whose events are sent, or 0 for every client.
+	ClientID  int
+	URL       string
+	Secret    string
+	CreatedAt time.Time
+}
+
+// Delivery is one event sent, or to be sent, to one webhook.
+type Delivery struct {
+	ID            int64
+	WebhookID     int64
+	Type          string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	// LastStatus is the HTTP status of the last attempt, or 0 when it got
+	// no response.
+	LastStatus  int
+	LastError   string
+	CreatedAt   time.Time
+	DeliveredAt time.Time
+	// URL and Secret are the webhook's, filled by DueDeliveries.
+	URL    string
+	Secret string
+}
+
+// DeliveryResult is the outcome of an attempt at a delivery.
+type DeliveryResult struct {
+	ID     int64
+	Status int
+	// Err is empty when the delivery succeeded.
+	Err string
+	// RetryAt is when to try again, or zero to give up. Only used when Err
+	// is set.
+	RetryAt time.Time
+}
+
+// UseWebhooks makes every write queue a delivery of its events to each
+// matching webhook, in the same transaction, from now on.
+func (p *Postgres) UseWebhooks() {
+	p.webhooks = true
+}
+
+// CreateWebhook fails with ErrClientNotFound when w.ClientID is set to an
+// unknown client.
+func (p *Postgres) CreateWebhook(ctx context.Context, w Webhook) (Webhook, error) {
+	var clientID *int
+	if w.ClientID != 0 {
+		clientID = &w.ClientID
+	}
+
+	err := p.pool().QueryRow(ctx,
+		`INSERT INTO bank.webhooks (client_id, url, secret) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		clientID,
+		w.URL,
+		w.Secret,
+	).Scan(&w.ID, &w.CreatedAt)
+
+	if isForeignKeyViolation(err) {
+		return Webhook{}, ErrClientNotFound
+	}
+
+	return w, err
+}
+
+func (p *Postgres) Webhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := p.pool().Query(ctx,
+		`SELECT id, COALESCE(client_id, 0), url, secret, created_at FROM bank.webhooks ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Webhook, error) {
+		var w Webhook
+		err := row.Scan(&w.ID, &w.ClientID, &w.URL, &w.Secret, &w.CreatedAt)
+		return w, err
+	})
+}
+
+// DeleteWebhook also drops its deliveries, pending ones included.
+func (p *Postgres) DeleteWebhook(ctx context.Context, id int64) error {
+	tag, err := p.pool().Exec(ctx, "DELETE FROM bank.webhooks WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// Deliveries returns up to limit of the webhook's deliveries, newest
+// first, keeping only those with the given status when it is set.
+func (p *Postgres) Deliveries(ctx context.Context, webhookID int64, status string, limit int) ([]Delivery, error) {
+	var exists bool
+	err := p.pool().QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM bank.webhooks WHERE id = $1)", webhookID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+
+	rows, err := p.pool().Query(ctx,
+		`
+		    SELECT id, webhook_id, "type", payload, status, attempts, next_attempt_at,
+		           COALESCE(last_status, 0), COALESCE(last_error, ''), created_at, delivered_at
+		    FROM bank.webhook_deliveries
+		    WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		    ORDER BY id DESC
+		    LIMIT $3
+		  `,
+		webhookID,
+		status,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Delivery, error) {
+		var d Delivery
+		var deliveredAt *time.Time
+		err := row.Scan(&d.ID, &d.WebhookID, &d.Type, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatus, &d.LastError, &d.CreatedAt, &deliveredAt)
+		if deliveredAt != nil {
+			d.DeliveredAt = *deliveredAt
+		}
+		return d, err
+	})
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt
+// is due, oldest first, with their webhook's URL and secret.
+func (p *Postgres) DueDeliveries(ctx context.Context, limit int) ([]Delivery, error) {
+	rows, err := p.pool().Query(ctx,
+		`
+		    SELECT d.id, d.webhook_id, d."type", d.payload, d.attempts, w.url, w.secret
+		    FROM bank.webhook_deliveries d
+		    JOIN bank.webhooks w ON w.id = d.webhook_id
+		    WHERE d.status = 'pending' AND d.next_attempt_at <= now()
+		    ORDER BY d.next_attempt_at, d.id
+		    LIMIT $1
+		  `,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Delivery, error) {
+		d := Delivery{Status: DeliveryPending}
+		err := row.Scan(&d.ID, &d.WebhookID, &d.Type, &d.Payload, &d.Attempts, &d.URL, &d.Secret)
+		return d, err
+	})
+}
+
+// RecordDeliveries stores the outcome of an attempt at each delivery.
+func (p *Postgres) RecordDeliveries(ctx context.Context, results []DeliveryResult) error {
+	batch := &pgx.Batch{}
+	for _, r := range results {
+		var lastStatus *int
+		if r.Status != 0 {
+			status := r.Status
+			lastStatus = &status
+		}
+
+		switch {
+		case r.Err == "":
+			batch.Queue(
+				`UPDATE bank.webhook_deliveries
+				SET status = 'delivered', attempts = attempts + 1, last_status = $2, last_error = NULL, delivered_at = now()
+				WHERE id = $1`,
+				r.ID,
+				lastStatus,
+			)
+		case r.RetryAt.IsZero():
+			batch.Queue(
+				`UPDATE bank.webhook_deliveries
+				SET status = 'failed', attempts = attempts + 1, last_status = $2, last_error = $3
+				WHERE id = $1`,
+				r.ID,
+				lastStatus,
+				r.Err,
+			)
+		default:
+			batch.Queue(
+				`UPDATE bank.webhook_deliveries
+				SET attempts = attempts + 1, last_status = $2, last_error = $3, next_attempt_at = $4
+				WHERE id = $1`,
+				r.ID,
+				lastStatus,
+				r.Err,
+				r.RetryAt,
+			)
+		}
+	}
+
+	return p.pool().SendBatch(ctx, batch).Close()
+}
@@ -0,0 +1,43 @@
+// Package requestid tags every request with an X-Request-ID, reusing the
+// caller's one when present, so log lines and responses can be correlated.
+package requestid
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// maxLength bounds incoming ids so a client can't inflate every log line.
+const maxLength = 128
+
+type ctxKey struct{}
+
+// New returns the middleware. It must run before anything that logs.
+func New() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := utils.CopyString(c.Get(fiber.HeaderXRequestID))
+		if id == "" || len(id) > maxLength {
+			id = utils.UUIDv4()
+		}
+
+		c.Set(fiber.HeaderXRequestID, id)
+		c.Locals(ctxKey{}, id)
+		c.SetUserContext(context.WithValue(c.UserContext(), ctxKey{}, id))
+
+		return c.Next()
+	}
+}
+
+// Get returns the id of the request being handled by c.
+func Get(c *fiber.Ctx) string {
+	id, _ := c.Locals(ctxKey{}).(string)
+	return id
+}
+
+// FromContext returns the id carried by a request's user context.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
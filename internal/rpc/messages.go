@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"fmt"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/encoding"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Requests decode the messages of proto/rinha.proto. Field numbers must be
+// kept in sync with that file.
+
+type CreateTransactionRequest struct {
+	ClientID       int64
+	Amount         int64
+	Type           string
+	Description    string
+	IdempotencyKey string
+}
+
+func (r *CreateTransactionRequest) UnmarshalProto(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v uint64, s []byte) {
+		switch num {
+		case 1:
+			r.ClientID = int64(v)
+		case 2:
+			r.Amount = int64(v)
+		case 3:
+			r.Type = string(s)
+		case 4:
+			r.Description = string(s)
+		case 5:
+			r.IdempotencyKey = string(s)
+		}
+	})
+}
+
+type GetStatementRequest struct {
+	ClientID int64
+	Limit    int64
+	Before   int64
+}
+
+func (r *GetStatementRequest) UnmarshalProto(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, v uint64, _ []byte) {
+		switch num {
+		case 1:
+			r.ClientID = int64(v)
+		case 2:
+			r.Limit = int64(v)
+		case 3:
+			r.Before = int64(v)
+		}
+	})
+}
+
+// consumeFields hands each varint field of b to set as v, and each
+// length-delimited one as s. Fields of other types are skipped.
+func consumeFields(b []byte, set func(num protowire.Number, v uint64, s []byte)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			set(num, v, nil)
+			b = b[n:]
+		case protowire.BytesType:
+			s, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			set(num, 0, s)
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+
+	return nil
+}
+
+type protoUnmarshaler interface {
+	UnmarshalProto(b []byte) error
+}
+
+// codec replaces the protobuf codec of gRPC, which needs generated
+// messages, with the hand-written encoders of the messages above and of
+// the REST DTOs.
+type codec struct{}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(encoding.ProtoAppender)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", encoding.ErrUnsupported, v)
+	}
+
+	return m.AppendProto(nil), nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(protoUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%w: %T", encoding.ErrUnsupported, v)
+	}
+
+	return m.UnmarshalProto(data)
+}
@@ -0,0 +1,175 @@
+// Package rpc serves the Bank service of proto/rinha.proto over gRPC, for
+// internal consumers that would rather skip JSON. It calls the same
+// service as the REST handlers and answers with the same DTOs.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/handler"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/metrics"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Service interface {
+	CreateTransaction(ctx context.Context, clientID int, t repository.Transaction) (repository.Client, repository.Transaction, error)
+	Statement(ctx context.Context, clientID int, q repository.StatementQuery) (repository.Client, []repository.Transaction, error)
+}
+
+// NewServer returns a gRPC server with the Bank service registered. Each
+// call is given timeout to finish, when positive, like REST requests.
+func NewServer(svc Service, timeout time.Duration, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ForceServerCodec(codec{}),
+		grpc.ChainUnaryInterceptor(deadline(timeout), translateErrors),
+	)
+
+	srv := grpc.NewServer(opts...)
+	srv.RegisterService(&serviceDesc, &bank{service: svc})
+
+	return srv
+}
+
+type bank struct {
+	service Service
+}
+
+func (b *bank) CreateTransaction(ctx context.Context, req *CreateTransactionRequest) (handler.TransactionCreatedDto, error) {
+	client, tr, err := b.service.CreateTransaction(ctx, int(req.ClientID), repository.Transaction{
+		Amount:         int(req.Amount),
+		Type:           req.Type,
+		Description:    req.Description,
+		IdempotencyKey: req.IdempotencyKey,
+	})
+
+	switch {
+	case errors.Is(err, repository.ErrLimitExceeded):
+		metrics.LimitRejected()
+	case errors.Is(err, repository.ErrClientNotFound):
+		metrics.ClientNotFound()
+	case err == nil:
+		metrics.Transaction(tr.Type, tr.Amount)
+	}
+
+	if err != nil {
+		return handler.TransactionCreatedDto{}, err
+	}
+
+	return handler.TransactionCreatedDto{
+		Limit:     client.Limit,
+		Balance:   client.Balance,
+		ID:        tr.ID,
+		CreatedAt: tr.CreatedAt,
+	}, nil
+}
+
+func (b *bank) GetStatement(ctx context.Context, req *GetStatementRequest) (handler.StatementResponseDto, error) {
+	q := repository.StatementQuery{Limit: int(req.Limit), Before: req.Before}
+	if q.Limit == 0 {
+		q.Limit = 10
+	}
+
+	client, transactions, err := b.service.Statement(ctx, int(req.ClientID), q)
+
+	if err != nil {
+		return handler.StatementResponseDto{}, err
+	}
+
+	res := handler.NewStatementResponse(client, transactions)
+
+	if len(transactions) == q.Limit {
+		res.NextPage = strconv.FormatInt(transactions[len(transactions)-1].ID, 10)
+	}
+
+	return res, nil
+}
+
+// serviceDesc is what protoc-gen-go-grpc would generate for the Bank
+// service, with the handlers calling bank directly.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "rinha.v1.Bank",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "CreateTransaction", Handler: unary("CreateTransaction", (*bank).CreateTransaction)},
+		{MethodName: "GetStatement", Handler: unary("GetStatement", (*bank).GetStatement)},
+	},
+	Metadata: "proto/rinha.proto",
+}
+
+// unary adapts a bank method to a grpc.MethodDesc handler, decoding its
+// request and running it through the interceptors.
+func unary[Req any, Res any](name string, method func(*bank, context.Context, *Req) (Res, error)) func(any, context.Context, func(any) error, grpc.UnaryServerInterceptor) (any, error) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/rinha.v1.Bank/" + name}
+
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		req := new(Req)
+		err := dec(req)
+		if err != nil {
+			return nil, err
+		}
+
+		call := func(ctx context.Context, req any) (any, error) {
+			return method(srv.(*bank), ctx, req.(*Req))
+		}
+		if interceptor == nil {
+			return call(ctx, req)
+		}
+
+		return interceptor(ctx, req, info, call)
+	}
+}
+
+// deadline gives each call timeout to finish, unless the client asked for
+// less.
+func deadline(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		if timeout <= 0 {
+			return next(ctx, req)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return next(ctx, req)
+	}
+}
+
+// translateErrors is the gRPC counterpart of handler.ErrorHandler, turning
+// errors into the status matching the REST one.
+func translateErrors(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	res, err := next(ctx, req)
+	if err == nil {
+		return res, nil
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, repository.ErrClientNotFound):
+		code = codes.NotFound
+	case errors.Is(err, service.ErrInvalidTransaction), errors.Is(err, service.ErrInvalidQuery):
+		code = codes.InvalidArgument
+	case errors.Is(err, repository.ErrLimitExceeded):
+		code = codes.FailedPrecondition
+	case errors.Is(err, repository.ErrBusy):
+		code = codes.Unavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	}
+
+	if code == codes.Internal {
+		slog.Error("Call failed", "method", info.FullMethod, "error", err)
+		return nil, status.Error(code, "internal error")
+	}
+
+	return nil, status.Error(code, err.Error())
+}
@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawCodec sends and receives messages already encoded, as *[]byte.
+type rawCodec struct{}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = data
+	return nil
+}
+
+// dial serves the Bank service over a Memory repository holding client 1,
+// with limite 1000, and returns a connection to it.
+func dial(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	repo := repository.NewMemory([]repository.Client{{ID: 1, Limit: 1000}})
+	svc := service.New(repo, repo)
+	err := svc.RefreshClients(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln := bufconn.Listen(1 << 16)
+	srv := NewServer(svc, time.Second)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// fields decodes the varint fields of a message, and counts the others.
+func fields(t *testing.T, b []byte) (map[protowire.Number]uint64, map[protowire.Number]int) {
+	t.Helper()
+
+	varints, others := map[protowire.Number]uint64{}, map[protowire.Number]int{}
+	err := consumeFields(b, func(num protowire.Number, v uint64, s []byte) {
+		if s != nil {
+			others[num]++
+		} else {
+			varints[num] = v
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return varints, others
+}
+
+func createTransaction(ctx context.Context, conn *grpc.ClientConn, clientID, amount int64, typ string) ([]byte, error) {
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.VarintType)
+	req = protowire.AppendVarint(req, uint64(clientID))
+	req = protowire.AppendTag(req, 2, protowire.VarintType)
+	req = protowire.AppendVarint(req, uint64(amount))
+	req = protowire.AppendTag(req, 3, protowire.BytesType)
+	req = protowire.AppendString(req, typ)
+	req = protowire.AppendTag(req, 4, protowire.BytesType)
+	req = protowire.AppendString(req, "grpc")
+
+	var res []byte
+	err := conn.Invoke(ctx, "/rinha.v1.Bank/CreateTransaction", &req, &res)
+	return res, err
+}
+
+func TestCreateTransaction(t *testing.T) {
+	conn := dial(t)
+	ctx := context.Background()
+
+	res, err := createTransaction(ctx, conn, 1, 300, "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	varints, others := fields(t, res)
+	if varints[1] != 1000 || protowire.DecodeZigZag(varints[2]) != -300 || varints[3] != 1 || others[4] != 1 {
+		t.Fatalf("got fields %v and %v", varints, others)
+	}
+
+	for _, tc := range []struct {
+		clientID, amount int64
+		typ              string
+		want             codes.Code
+	}{
+		{9, 1, "c", codes.NotFound},
+		{1, 1, "x", codes.InvalidArgument},
+		{1, 701, "d", codes.FailedPrecondition},
+	} {
+		_, err := createTransaction(ctx, conn, tc.clientID, tc.amount, tc.typ)
+		if status.Code(err) != tc.want {
+			t.Fatalf("%+v: got %v, want %s", tc, err, tc.want)
+		}
+	}
+}
+
+func TestGetStatement(t *testing.T) {
+	conn := dial(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := createTransaction(ctx, conn, 1, 10, "c")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.VarintType)
+	req = protowire.AppendVarint(req, 1)
+	req = protowire.AppendTag(req, 2, protowire.VarintType)
+	req = protowire.AppendVarint(req, 2)
+
+	var res []byte
+	err := conn.Invoke(ctx, "/rinha.v1.Bank/GetStatement", &req, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A full page of two transactions, pointing at the third.
+	_, others := fields(t, res)
+	if others[1] != 1 || others[2] != 2 || others[3] != 1 {
+		t.Fatalf("got fields %v", others)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+var ErrInvalidClient = errors.New("invalid client")
+
+// CreateClient registers a new client with a zero balance.
+func (s *Service) CreateClient(ctx context.Context, c repository.Client) (repository.Client, error) {
+	if c.ID < 1 {
+		return repository.Client{}, fmt.Errorf("%w: id must be positive", ErrInvalidClient)
+	}
+
+	if c.Limit < 0 {
+		return repository.Client{}, fmt.Errorf("%w: limite must not be negative", ErrInvalidClient)
+	}
+
+	c.Balance = 0
+
+	err := s.clients.CreateClient(ctx, c)
+	if err != nil {
+		return repository.Client{}, err
+	}
+
+	s.remember(c.ID, true)
+
+	return c, nil
+}
+
+func (s *Service) Client(ctx context.Context, id int) (repository.Client, error) {
+	return s.clients.FindClient(ctx, id)
+}
+
+// UpdateLimit changes the client's limit, as long as the current balance
+// stays within it.
+func (s *Service) UpdateLimit(ctx context.Context, id int, limit int) (repository.Client, error) {
+	if limit < 0 {
+		return repository.Client{}, fmt.Errorf("%w: limite must not be negative", ErrInvalidClient)
+	}
+
+	c, err := s.clients.UpdateLimit(ctx, id, limit)
+	if err != nil {
+		return repository.Client{}, err
+	}
+
+	s.publish(id, c)
+
+	return c, nil
+}
+
+func (s *Service) DeleteClient(ctx context.Context, id int) error {
+	err := s.clients.DeleteClient(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	s.remember(id, false)
+
+	return nil
+}
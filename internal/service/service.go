@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/pubsub"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+var (
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	ErrInvalidQuery       = errors.New("invalid query")
+)
+
+// MaxStatementLimit caps how many transactions a statement page may hold.
+const MaxStatementLimit = 100
+
+type Service struct {
+	clients      repository.ClientRepository
+	transactions repository.TransactionRepository
+
+	// known caches the existing client ids so most requests skip the
+	// database round trip; ids missing from it are looked up in storage.
+	mu    sync.RWMutex
+	known map[int]struct{}
+	// changed records the clients created (true) or deleted (false) here
+	// while a refresh is loading, so it doesn't undo them.
+	changed map[int]bool
+
+	// hub, when set, receives the client changed by each write.
+	hub *pubsub.Hub[repository.Client]
+}
+
+func New(clients repository.ClientRepository, transactions repository.TransactionRepository) *Service {
+	return &Service{clients: clients, transactions: transactions}
+}
+
+// RefreshClients reloads the set of known client ids from storage, keeping
+// the clients created or deleted here meanwhile as they are.
+func (s *Service) RefreshClients(ctx context.Context) error {
+	s.mu.Lock()
+	s.changed = make(map[int]bool)
+	s.mu.Unlock()
+
+	ids, err := s.clients.ClientIDs(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := s.changed
+	s.changed = nil
+
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int]struct{}, len(ids))
+	for _, id := range ids {
+		known[id] = struct{}{}
+	}
+
+	for id, exists := range changed {
+		if exists {
+			known[id] = struct{}{}
+		} else {
+			delete(known, id)
+		}
+	}
+
+	s.known = known
+
+	return nil
+}
+
+// WatchClients calls RefreshClients every interval until ctx is done.
+func (s *Service) WatchClients(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.RefreshClients(ctx)
+			if err != nil {
+				slog.Error("Unable to refresh clients", "error", err)
+			}
+		}
+	}
+}
+
+// checkClient fails with repository.ErrClientNotFound unless the client
+// exists. Ids not yet known, e.g. of clients created by another replica
+// since the last refresh, are looked up in storage.
+func (s *Service) checkClient(ctx context.Context, id int) error {
+	s.mu.RLock()
+	_, ok := s.known[id]
+	s.mu.RUnlock()
+
+	if ok {
+		return nil
+	}
+
+	_, err := s.clients.FindClient(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	s.remember(id, true)
+
+	return nil
+}
+
+// remember records that the client was created (exists) or deleted.
+func (s *Service) remember(id int, exists bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changed != nil {
+		s.changed[id] = exists
+	}
+
+	if exists {
+		if s.known == nil {
+			s.known = make(map[int]struct{})
+		}
+		s.known[id] = struct{}{}
+	} else {
+		delete(s.known, id)
+	}
+}
+
+// CreateTransaction validates t and applies it to the client's balance,
+// returning the updated client and the stored transaction.
+func (s *Service) CreateTransaction(ctx context.Context, clientID int, t repository.Transaction) (repository.Client, repository.Transaction, error) {
+	err := s.checkClient(ctx, clientID)
+	if err != nil {
+		return repository.Client{}, repository.Transaction{}, err
+	}
+
+	err = validateTransaction(t)
+	if err != nil {
+		return repository.Client{}, repository.Transaction{}, err
+	}
+
+	c, stored, err := s.clients.ApplyTransaction(ctx, clientID, t)
+	if err != nil {
+		return repository.Client{}, repository.Transaction{}, err
+	}
+
+	s.publish(clientID, c)
+
+	return c, stored, nil
+}
+
+// Statement returns the client and the transactions selected by q, newest
+// first.
+func (s *Service) Statement(ctx context.Context, clientID int, q repository.StatementQuery) (repository.Client, []repository.Transaction, error) {
+	err := s.checkClient(ctx, clientID)
+	if err != nil {
+		return repository.Client{}, nil, err
+	}
+
+	if q.Limit < 1 || q.Limit > MaxStatementLimit {
+		return repository.Client{}, nil, fmt.Errorf("%w: limite must be between 1 and %d", ErrInvalidQuery, MaxStatementLimit)
+	}
+
+	if q.Offset < 0 {
+		return repository.Client{}, nil, fmt.Errorf("%w: pagina must be positive", ErrInvalidQuery)
+	}
+
+	if q.Before < 0 {
+		return repository.Client{}, nil, fmt.Errorf("%w: antes_de must be positive", ErrInvalidQuery)
+	}
+
+	if !q.From.IsZero() && !q.To.IsZero() && q.From.After(q.To) {
+		return repository.Client{}, nil, fmt.Errorf("%w: de must not be after ate", ErrInvalidQuery)
+	}
+
+	return s.transactions.Statement(ctx, clientID, q)
+}
+
+// maxBatchSize caps how many transactions a single batch may carry.
+const maxBatchSize = 100
+
+// CreateTransactions validates every item and applies them all-or-nothing,
+// returning the client after each one. Failures are *repository.BatchError
+// unless the whole request is invalid.
+func (s *Service) CreateTransactions(ctx context.Context, clientID int, ts []repository.Transaction) ([]repository.Client, error) {
+	err := s.checkClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ts) < 1 || len(ts) > maxBatchSize {
+		return nil, fmt.Errorf("%w: batch must have between 1 and %d items", ErrInvalidTransaction, maxBatchSize)
+	}
+
+	for i, t := range ts {
+		err := validateTransaction(t)
+		if err != nil {
+			return nil, &repository.BatchError{Index: i, Err: err}
+		}
+	}
+
+	clients, err := s.clients.ApplyTransactions(ctx, clientID, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(clientID, clients[len(clients)-1])
+
+	return clients, nil
+}
+
+// Transfer moves amount from one client to another and returns the updated
+// sender.
+func (s *Service) Transfer(ctx context.Context, fromID, toID int, t repository.Transaction) (repository.Client, error) {
+	err := s.checkClient(ctx, fromID)
+	if err != nil {
+		return repository.Client{}, err
+	}
+
+	err = s.checkClient(ctx, toID)
+	if err != nil {
+		return repository.Client{}, err
+	}
+
+	if fromID == toID {
+		return repository.Client{}, fmt.Errorf("%w: cannot transfer to the same client", ErrInvalidTransaction)
+	}
+
+	t.Type = "d"
+	err = validateTransaction(t)
+	if err != nil {
+		return repository.Client{}, err
+	}
+
+	c, err := s.clients.Transfer(ctx, fromID, toID, t)
+	if err != nil {
+		return repository.Client{}, err
+	}
+
+	s.publish(fromID, c)
+	s.publishID(ctx, toID)
+
+	return c, nil
+}
+
+// Reverse compensates a previous transaction of the client and returns the
+// updated client.
+func (s *Service) Reverse(ctx context.Context, clientID int, transactionID int64) (repository.Client, error) {
+	err := s.checkClient(ctx, clientID)
+	if err != nil {
+		return repository.Client{}, err
+	}
+
+	c, err := s.clients.Reverse(ctx, clientID, transactionID)
+	if err != nil {
+		return repository.Client{}, err
+	}
+
+	s.publish(clientID, c)
+
+	return c, nil
+}
+
+func (s *Service) Transaction(ctx context.Context, clientID int, id int64) (repository.Transaction, error) {
+	err := s.checkClient(ctx, clientID)
+	if err != nil {
+		return repository.Transaction{}, err
+	}
+
+	return s.transactions.FindTransaction(ctx, clientID, id)
+}
+
+// CheckClient fails with repository.ErrClientNotFound unless the client
+// exists.
+func (s *Service) CheckClient(ctx context.Context, clientID int) error {
+	return s.checkClient(ctx, clientID)
+}
+
+// ExportTransactions streams every transaction of the client to fn, oldest
+// first.
+func (s *Service) ExportTransactions(ctx context.Context, clientID int, fn func(repository.Transaction) error) error {
+	err := s.checkClient(ctx, clientID)
+	if err != nil {
+		return err
+	}
+
+	return s.transactions.EachTransaction(ctx, clientID, fn)
+}
+
+// Balance returns the client's balance and limit without its transactions.
+func (s *Service) Balance(ctx context.Context, clientID int) (repository.Client, error) {
+	err := s.checkClient(ctx, clientID)
+	if err != nil {
+		return repository.Client{}, err
+	}
+
+	return s.clients.FindClient(ctx, clientID)
+}
+
+// maxIdempotencyKeyLength matches the idempotency_keys column size.
+const maxIdempotencyKeyLength = 255
+
+func validateTransaction(t repository.Transaction) error {
+	if len(t.IdempotencyKey) > maxIdempotencyKeyLength {
+		return fmt.Errorf("%w: Idempotency-Key must have at most %d characters", ErrInvalidTransaction, maxIdempotencyKeyLength)
+	}
+
+	// A non-positive valor would move the balance the wrong way, e.g. a
+	// negative credit acting as a debit that skips the limit check.
+	if t.Amount < 1 {
+		return fmt.Errorf("%w: valor must be at least 1, got %d", ErrInvalidTransaction, t.Amount)
+	}
+
+	if len(t.Description) < 1 || len(t.Description) > 10 {
+		return fmt.Errorf("%w: descricao must have between 1 and 10 characters", ErrInvalidTransaction)
+	}
+
+	if t.Type != "c" && t.Type != "d" {
+		return fmt.Errorf("%w: invalid type %s", ErrInvalidTransaction, t.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/service"
+)
+
+var debit = repository.Transaction{Amount: 10, Type: "d", Description: "compra"}
+
+func TestServiceClientCreatedElsewhere(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemory([]repository.Client{{ID: 1, Limit: 1000}})
+	svc := service.New(repo, repo)
+	err := svc.RefreshClients(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As by another replica, after the refresh.
+	err = repo.CreateClient(ctx, repository.Client{ID: 2, Limit: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, _, err := svc.CreateTransaction(ctx, 2, debit)
+	if err != nil || c.Balance != -10 {
+		t.Fatalf("got %+v, %v", c, err)
+	}
+
+	_, _, err = svc.CreateTransaction(ctx, 3, debit)
+	if !errors.Is(err, repository.ErrClientNotFound) {
+		t.Fatalf("got %v, want ErrClientNotFound", err)
+	}
+}
+
+// slowIDs holds ClientIDs until release is closed, once loaded, and
+// counts the clients looked up.
+type slowIDs struct {
+	*repository.Memory
+	loaded  chan struct{}
+	release chan struct{}
+	found   int
+}
+
+func (r *slowIDs) FindClient(ctx context.Context, id int) (repository.Client, error) {
+	r.found++
+	return r.Memory.FindClient(ctx, id)
+}
+
+func (r *slowIDs) ClientIDs(ctx context.Context) ([]int, error) {
+	ids, err := r.Memory.ClientIDs(ctx)
+	close(r.loaded)
+	<-r.release
+	return ids, err
+}
+
+func TestServiceRefreshKeepsConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	memory := repository.NewMemory([]repository.Client{{ID: 1, Limit: 1000}})
+	repo := &slowIDs{Memory: memory, loaded: make(chan struct{}), release: make(chan struct{})}
+	svc := service.New(repo, memory)
+
+	refreshed := make(chan error)
+	go func() { refreshed <- svc.RefreshClients(ctx) }()
+	<-repo.loaded
+
+	_, err := svc.CreateClient(ctx, repository.Client{ID: 2, Limit: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = svc.DeleteClient(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(repo.release)
+	err = <-refreshed
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.CheckClient(ctx, 2)
+	if err != nil || repo.found != 0 {
+		t.Fatalf("created client: got %v after %d lookups", err, repo.found)
+	}
+
+	err = svc.CheckClient(ctx, 1)
+	if !errors.Is(err, repository.ErrClientNotFound) {
+		t.Fatalf("deleted client: got %v, want ErrClientNotFound", err)
+	}
+}
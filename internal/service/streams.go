@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/pubsub"
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+// ErrStreamsUnavailable means the service was not given a hub to stream
+// updates from.
+var ErrStreamsUnavailable = errors.New("streams unavailable")
+
+// UseHub makes every write publish the client it changed to hub, from now
+// on. Only writes served by this process are published.
+func (s *Service) UseHub(hub *pubsub.Hub[repository.Client]) {
+	s.hub = hub
+}
+
+// Streams reports whether responses may be streamed, which the service is
+// told by being given a hub.
+func (s *Service) Streams() bool {
+	return s.hub != nil
+}
+
+// Subscribe returns a subscription to the client's updates, each the
+// client right after a write.
+func (s *Service) Subscribe(ctx context.Context, clientID int) (*pubsub.Subscription[repository.Client], error) {
+	if s.hub == nil {
+		return nil, ErrStreamsUnavailable
+	}
+
+	err := s.checkClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.hub.Subscribe(clientID), nil
+}
+
+func (s *Service) publish(id int, c repository.Client) {
+	if s.hub != nil {
+		c.ID = id
+		s.hub.Publish(id, c)
+	}
+}
+
+// publishID publishes a client the write didn't return, loading it only
+// when someone is watching.
+func (s *Service) publishID(ctx context.Context, id int) {
+	if s.hub == nil || !s.hub.Watched(id) {
+		return
+	}
+
+	c, err := s.clients.FindClient(ctx, id)
+	if err != nil {
+		slog.Warn("Unable to load client to publish", "client", id, "error", err)
+		return
+	}
+
+	s.publish(id, c)
+}
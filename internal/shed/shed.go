@@ -0,0 +1,79 @@
+// Package shed rejects requests up front while the service is overloaded,
+// so tail latency stays bounded instead of requests queuing for the pool.
+package shed
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrOverloaded is returned for shed requests.
+var ErrOverloaded = errors.New("service overloaded")
+
+// Shedder tracks the requests in flight and whether the pool is saturated.
+type Shedder struct {
+	maxInFlight int64
+	inFlight    atomic.Int64
+	saturated   atomic.Bool
+}
+
+// New returns a Shedder allowing up to maxInFlight concurrent requests. Zero
+// leaves them unbounded.
+func New(maxInFlight int) *Shedder {
+	return &Shedder{maxInFlight: int64(maxInFlight)}
+}
+
+// Middleware fails requests with ErrOverloaded and a Retry-After header
+// while the pool is saturated or too many requests are in flight.
+func (s *Shedder) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		n := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		if s.saturated.Load() || (s.maxInFlight > 0 && n > s.maxInFlight) {
+			c.Set(fiber.HeaderRetryAfter, "1")
+			return ErrOverloaded
+		}
+
+		return c.Next()
+	}
+}
+
+// Pool is a *pgxpool.Pool, or anything standing in front of one.
+type Pool interface {
+	Stat() *pgxpool.Stat
+}
+
+// WatchPool samples pool.Stat() every interval until ctx is done, marking
+// the pool saturated while the mean acquire wait over the last interval is
+// above maxWait. Acquires still waiting are not counted until they finish;
+// the in-flight bound covers a pool that is stuck outright.
+func (s *Shedder) WatchPool(ctx context.Context, pool Pool, interval, maxWait time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	stat := pool.Stat()
+	count, wait := stat.AcquireCount(), stat.AcquireDuration()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stat := pool.Stat()
+
+			var mean time.Duration
+			if n := stat.AcquireCount() - count; n > 0 {
+				mean = (stat.AcquireDuration() - wait) / time.Duration(n)
+			}
+
+			s.saturated.Store(mean > maxWait)
+			count, wait = stat.AcquireCount(), stat.AcquireDuration()
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package shed
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMiddleware(t *testing.T) {
+	s := New(1)
+
+	app := fiber.New(fiber.Config{ErrorHandler: func(c *fiber.Ctx, err error) error {
+		if errors.Is(err, ErrOverloaded) {
+			return c.SendStatus(503)
+		}
+		return c.SendStatus(500)
+	}})
+	app.Use(s.Middleware())
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		close(entered)
+		<-release
+		return nil
+	})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return nil
+	})
+
+	get := func(target string) int {
+		res, err := app.Test(httptest.NewRequest("GET", target, nil), -1)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		if res.StatusCode == 503 && res.Header.Get(fiber.HeaderRetryAfter) != "1" {
+			t.Error("shed without Retry-After")
+		}
+		return res.StatusCode
+	}
+
+	done := make(chan int)
+	go func() { done <- get("/slow") }()
+	<-entered
+
+	if status := get("/"); status != 503 {
+		t.Fatalf("got %d past the in-flight bound, want 503", status)
+	}
+
+	close(release)
+	if status := <-done; status != 200 {
+		t.Fatalf("got %d for the request in flight", status)
+	}
+
+	if status := get("/"); status != 200 {
+		t.Fatalf("got %d once drained", status)
+	}
+
+	s.saturated.Store(true)
+	if status := get("/"); status != 503 {
+		t.Fatalf("got %d with the pool saturated, want 503", status)
+	}
+}
@@ -0,0 +1,58 @@
+// Package tracing exports OpenTelemetry traces over OTLP/HTTP. The exporter,
+// sampler and resource follow the standard OTEL_ environment variables, e.g.
+// OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_TRACES_SAMPLER and OTEL_SERVICE_NAME.
+package tracing
+
+import (
+	"context"
+
+	"github.com/exaring/otelpgx"
+	"github.com/gofiber/contrib/otelfiber/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// serviceName is used unless OTEL_SERVICE_NAME is set.
+const serviceName = "rinha-api"
+
+// Setup installs the global tracer provider and propagators. The returned
+// function flushes pending spans.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp.Shutdown, nil
+}
+
+// Middleware starts a span per request and puts it in the user context, so
+// the queries the handler runs become its children.
+func Middleware() fiber.Handler {
+	return otelfiber.Middleware()
+}
+
+// QueryTracer creates a span per SQL query.
+func QueryTracer() pgx.QueryTracer {
+	return otelpgx.NewTracer()
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget means a webhook points at an address deliveries are
+// never sent to.
+var ErrForbiddenTarget = errors.New("webhook target not allowed")
+
+// public reports whether deliveries may be sent to ip. Anyone registering a
+// webhook picks its URL, so loopback, private and link-local addresses,
+// like cloud metadata endpoints, would let them reach internal services.
+func public(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// CheckURL refuses a webhook URL whose host is a localhost name or an
+// address that isn't public. Other names resolving to such addresses are
+// only caught when delivering.
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrForbiddenTarget, host)
+	}
+
+	if ip := net.ParseIP(host); ip != nil && !public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenTarget, host)
+	}
+
+	return nil
+}
+
+// newClient returns a client that only connects to public addresses. The
+// check runs on the address being dialed, after resolving, so neither
+// redirects nor DNS answers can lead it elsewhere. Proxies are not used,
+// since the proxy would be the one dialed.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !public(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenTarget, host)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,210 @@
+// Package webhook delivers events to the URLs registered for them as signed
+// POSTs, retrying failures with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+// Headers sent with every delivery.
+const (
+	// SignatureHeader is "t=<unix time>,v1=<hex HMAC-SHA256>", signing the
+	// time, a dot and the body with the webhook's secret. Receivers should
+	// reject old times to stop replays.
+	SignatureHeader = "X-Webhook-Signature"
+	// IDHeader is the delivery id, the same on every attempt, so receivers
+	// can drop duplicates.
+	IDHeader    = "X-Webhook-Id"
+	EventHeader = "X-Webhook-Event"
+)
+
+// Backoff bounds: the first retry waits minBackoff and each next one twice
+// as long, up to maxBackoff.
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Hour
+)
+
+// NewSecret returns a random signing secret, as 64 hex digits.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the SignatureHeader value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Store is where deliveries wait.
+type Store interface {
+	DueDeliveries(ctx context.Context, limit int) ([]repository.Delivery, error)
+	RecordDeliveries(ctx context.Context, results []repository.DeliveryResult) error
+}
+
+type Dispatcher struct {
+	store       Store
+	client      *http.Client
+	batch       int
+	maxAttempts int
+}
+
+// NewDispatcher sends up to batch deliveries at once, each given timeout to
+// be answered with a 2xx, and gives up on one after maxAttempts. Deliveries
+// are only sent to public addresses.
+func NewDispatcher(store Store, batch int, timeout time.Duration, maxAttempts int) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		client:      newClient(timeout),
+		batch:       batch,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run sends the due deliveries every interval until ctx is done. Only one
+// replica should run it, or deliveries would be sent twice.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := d.dispatch(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Error("Unable to dispatch webhooks", "error", err)
+				break
+			}
+
+			if n < d.batch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch attempts one batch of due deliveries concurrently, returning
+// how many there were.
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	deliveries, err := d.store.DueDeliveries(ctx, d.batch)
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+
+	results := make([]repository.DeliveryResult, len(deliveries))
+
+	var wg sync.WaitGroup
+	for i, delivery := range deliveries {
+		wg.Add(1)
+		go func(i int, delivery repository.Delivery) {
+			defer wg.Done()
+			results[i] = d.attempt(ctx, delivery)
+		}(i, delivery)
+	}
+	wg.Wait()
+
+	// Attempts cut short by ctx don't count; the ones that succeeded are
+	// still recorded, so they aren't sent again.
+	if ctx.Err() != nil {
+		results = slices.DeleteFunc(results, func(r repository.DeliveryResult) bool {
+			return r.Err != ""
+		})
+	}
+
+	err = d.store.RecordDeliveries(context.WithoutCancel(ctx), results)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery repository.Delivery) repository.DeliveryResult {
+	result := repository.DeliveryResult{ID: delivery.ID}
+
+	result.Status, result.Err = d.send(ctx, delivery)
+	if result.Err == "" {
+		return result
+	}
+
+	log := slog.With("delivery", delivery.ID, "webhook", delivery.WebhookID, "error", result.Err)
+
+	attempts := delivery.Attempts + 1
+	if attempts >= d.maxAttempts {
+		log.Error("Webhook delivery failed, giving up", "attempts", attempts)
+		return result
+	}
+
+	backoff := maxBackoff
+	if attempts < 32 {
+		backoff = min(minBackoff<<(attempts-1), maxBackoff)
+	}
+	result.RetryAt = time.Now().Add(backoff)
+
+	log.Warn("Webhook delivery failed, retrying", "in", backoff)
+
+	return result
+}
+
+// send POSTs the delivery, returning the response status, if any, and why
+// it failed, empty when it didn't.
+func (d *Dispatcher) send(ctx context.Context, delivery repository.Delivery) (int, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(EventHeader, delivery.Type)
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	// Drained so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, ""
+}
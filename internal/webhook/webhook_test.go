@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NathanFirmo/rinha-de-backend-2024-q1/internal/repository"
+)
+
+// fakeStore hands out deliveries once and keeps the results recorded.
+type fakeStore struct {
+	due     []repository.Delivery
+	results []repository.DeliveryResult
+}
+
+func (s *fakeStore) DueDeliveries(_ context.Context, limit int) ([]repository.Delivery, error) {
+	due := s.due[:min(limit, len(s.due))]
+	s.due = s.due[len(due):]
+	return due, nil
+}
+
+func (s *fakeStore) RecordDeliveries(_ context.Context, results []repository.DeliveryResult) error {
+	s.results = append(s.results, results...)
+	return nil
+}
+
+func TestDispatch(t *testing.T) {
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(500)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+	}))
+	defer srv.Close()
+
+	payload := []byte(`{"id":1}`)
+	store := &fakeStore{due: []repository.Delivery{
+		{ID: 1, Type: "transacao_criada", Payload: payload, URL: srv.URL + "/ok", Secret: "s"},
+		{ID: 2, Attempts: 1, Payload: payload, URL: srv.URL + "/down", Secret: "s"},
+		{ID: 3, Attempts: 2, Payload: payload, URL: srv.URL + "/down", Secret: "s"},
+	}}
+	d := NewDispatcher(store, 10, time.Second, 3)
+	// The test server listens on loopback, which deliveries never reach.
+	d.client = srv.Client()
+
+	n, err := d.dispatch(context.Background())
+	if err != nil || n != 3 {
+		t.Fatalf("dispatched %d, error %v", n, err)
+	}
+
+	got := <-requests
+	if string(got.body) != string(payload) || got.header.Get(IDHeader) != "1" || got.header.Get(EventHeader) != "transacao_criada" {
+		t.Fatalf("got headers %v with body %s", got.header, got.body)
+	}
+
+	signature := got.header.Get(SignatureHeader)
+	ts, _, _ := strings.Cut(strings.TrimPrefix(signature, "t="), ",")
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || signature != Sign("s", time.Unix(unix, 0), payload) {
+		t.Fatalf("got signature %q", signature)
+	}
+
+	delivered, retried, failed := store.results[0], store.results[1], store.results[2]
+	if delivered.Err != "" || delivered.Status != 200 {
+		t.Fatalf("got %+v, want delivered", delivered)
+	}
+	if retried.Status != 500 || retried.RetryAt.Sub(time.Now()) <= time.Second {
+		t.Fatalf("got %+v, want a retry in 2s", retried)
+	}
+	if failed.Err == "" || !failed.RetryAt.IsZero() {
+		t.Fatalf("got %+v, want given up", failed)
+	}
+}
+
+func TestDispatchRefusesPrivateAddresses(t *testing.T) {
+	var hit atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		hit.Store(true)
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{due: []repository.Delivery{{ID: 1, URL: srv.URL, Secret: "s"}}}
+	d := NewDispatcher(store, 10, time.Second, 3)
+
+	_, err := d.dispatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hit.Load() || !strings.Contains(store.results[0].Err, ErrForbiddenTarget.Error()) {
+		t.Fatalf("got %+v after reaching the server: %v", store.results[0], hit.Load())
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	for url, allowed := range map[string]bool{
+		"https://example.com/hook":           true,
+		"http://203.0.113.7:8080/hook":       true,
+		"http://localhost:9999/hook":         false,
+		"http://api.localhost/hook":          false,
+		"http://127.0.0.1/hook":              false,
+		"http://10.0.0.5/hook":               false,
+		"http://192.168.1.1/hook":            false,
+		"http://169.254.169.254/latest":      false,
+		"http://[::1]/hook":                  false,
+		"http://[fe80::1]/hook":              false,
+		"http://[::ffff:127.0.0.1]/hook":     false,
+		"http://0.0.0.0/hook":                false,
+		"http://[fd00:ec2::254]/latest/meta": false,
+	} {
+		err := CheckURL(url)
+		if allowed != (err == nil) || (err != nil && !errors.Is(err, ErrForbiddenTarget)) {
+			t.Errorf("%s: got %v", url, err)
+		}
+	}
+}